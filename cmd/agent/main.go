@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os/signal"
+	"syscall"
 
 	"go-yandex-metrics/internal/agent/api"
 	"go-yandex-metrics/internal/config"
@@ -30,7 +33,10 @@ func run() error {
 		return fmt.Errorf("failed to create agent: %w", err)
 	}
 
-	err = agent.Start()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = agent.Start(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to start agent: %w", err)
 	}
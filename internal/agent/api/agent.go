@@ -1,14 +1,18 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/hashicorp/go-retryablehttp"
 	"go.uber.org/zap"
 
+	"go-yandex-metrics/internal/agent/collector"
 	"go-yandex-metrics/internal/config"
 	logger "go-yandex-metrics/internal/server/middleware"
 )
@@ -20,16 +24,22 @@ type AgentCfg struct {
 }
 
 type Agent struct {
-	logger *zap.Logger
-	store  *MemStorage
-	client *http.Client
-	cfg    config.AgentCfg
+	started      time.Time
+	logger       *zap.Logger
+	store        *MemStorage
+	lastSent     *atomic.Int64
+	destinations []*destination
+	routes       []*route
+	collectors   []collector.Collector
+	cfg          config.AgentCfg
 }
 
 type MemStorage struct {
-	Gauge   map[string]float64 `json:"gauge"`
-	Counter map[string]int64   `json:"counter"`
-	memLock *sync.Mutex
+	Gauge      map[string]float64 `json:"gauge"`
+	Counter    map[string]int64   `json:"counter"`
+	Total      map[string]int64   `json:"total"`
+	aggregates map[string]*gaugeAggregate
+	memLock    *sync.Mutex
 }
 
 func NewAgent(cfg config.AgentCfg, store *MemStorage) (*Agent, error) {
@@ -38,50 +48,76 @@ func NewAgent(cfg config.AgentCfg, store *MemStorage) (*Agent, error) {
 		return nil, fmt.Errorf("failed to init logger: %w", err)
 	}
 
-	retClient := retryablehttp.NewClient()
-	retClient.Backoff = Backoff
+	destinations := make([]*destination, 0, len(cfg.Destinations))
+	for _, d := range cfg.Destinations {
+		dest, err := newDestination(cfg, d)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, dest)
+	}
 
-	retClient.RetryMax = 3
-	retClient.RetryWaitMin = 1 * time.Second
-	retClient.RetryWaitMax = 5 * time.Second
+	if err := validateAggregation(cfg.Aggregate); err != nil {
+		return nil, fmt.Errorf("invalid aggregation config: %w", err)
+	}
 
-	stClient := retClient.StandardClient()
+	collectors, err := collector.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create collectors: %w", err)
+	}
 
 	agt := &Agent{
-		logger: lg,
-		store:  store,
-		client: stClient,
-		cfg:    cfg,
+		started:      time.Now(),
+		lastSent:     &atomic.Int64{},
+		logger:       lg,
+		store:        store,
+		destinations: destinations,
+		collectors:   collectors,
+		cfg:          cfg,
+	}
+
+	agt.routes, err = agt.newRoutes()
+	if err != nil {
+		return nil, err
 	}
 	return agt, nil
 }
 
-func Backoff(minValue, maxValue time.Duration, attemptNum int, resp *http.Response) time.Duration {
-	switch attemptNum {
-	case 0:
-		return 1 * time.Second
-	case 1:
-		return 3 * time.Second
-	case 2:
-		return 5 * time.Second
-	default:
-		return 3 * time.Second
+// Start runs collectors and reports until ctx is cancelled, then does one
+// last collection and send within ShutdownTimeout, so counter deltas of the
+// last report window are not lost.
+func (a *Agent) Start(ctx context.Context) error {
+	var pullServer *http.Server
+	if a.cfg.PullAddress != "" {
+		pullServer = a.startPullServer()
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, c := range a.collectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.runCollector(ctx, c)
+		}()
 	}
-}
 
-func (a *Agent) Start() error {
-	tickerSave := time.NewTicker(time.Duration(a.cfg.PollInterval) * time.Second)
 	tickerSend := time.NewTicker(time.Duration(a.cfg.ReportInterval) * time.Second)
+	defer tickerSend.Stop()
 	batchSend := time.NewTicker(time.Duration(a.cfg.ReportInterval) * time.Second)
+	defer batchSend.Stop()
 
 	for {
 		select {
-		case <-tickerSave.C:
-			a.saveMetrics()
+		case <-ctx.Done():
+			wg.Wait()
+			return a.shutdown(pullServer)
 		case <-tickerSend.C:
-			a.sendMetrics()
+			err := a.sendMetrics(ctx)
+			if err != nil {
+				a.logger.Info("an error occured sending metrics", zap.Error(err))
+			}
 		case <-batchSend.C:
-			err := a.sendMetricsBatch()
+			err := a.sendMetricsBatch(ctx)
 			if err != nil {
 				a.logger.Error("failed to send a batch of metrics", zap.Error(err))
 			}
@@ -89,10 +125,69 @@ func (a *Agent) Start() error {
 	}
 }
 
+func (a *Agent) shutdown(pullServer *http.Server) error {
+	a.logger.Info("shutting down agent, sending the last batch")
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout.Duration)
+	defer cancel()
+
+	var errs []error
+	for _, c := range a.collectors {
+		a.collect(ctx, c)
+		if closer, ok := c.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close collector %s: %w", c.Name(), err))
+			}
+		}
+	}
+
+	if err := a.sendMetricsBatch(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to send the last batch: %w", err))
+	}
+
+	if pullServer != nil {
+		if err := pullServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop pull endpoint: %w", err))
+		}
+	}
+
+	_ = a.logger.Sync()
+
+	return errors.Join(errs...)
+}
+
+func (a *Agent) runCollector(ctx context.Context, c collector.Collector) {
+	ticker := time.NewTicker(c.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.collect(ctx, c)
+		}
+	}
+}
+
+func (a *Agent) collect(ctx context.Context, c collector.Collector) {
+	ctx, cancel := context.WithTimeout(ctx, c.Interval())
+	defer cancel()
+
+	metrics, err := c.Collect(ctx)
+	if err != nil {
+		a.logger.Info("collector returned an error", zap.String("collector", c.Name()), zap.Error(err))
+	}
+
+	a.saveMetrics(metrics)
+}
+
 func NewAgentMemStorage(cfg config.AgentCfg) (*MemStorage, error) {
 	return &MemStorage{
-		Gauge:   make(map[string]float64),
-		Counter: make(map[string]int64),
-		memLock: &sync.Mutex{},
+		Gauge:      make(map[string]float64),
+		Counter:    make(map[string]int64),
+		Total:      make(map[string]int64),
+		aggregates: make(map[string]*gaugeAggregate),
+		memLock:    &sync.Mutex{},
 	}, nil
 }
@@ -1,33 +1,26 @@
 package api
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"math/rand"
-	"net/http"
 	"net/url"
-	"runtime"
-	"strconv"
+	"sync"
 
 	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/agent/collector"
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/pkg/client"
 )
 
 const (
 	GaugeType   string = "gauge"
 	CounterType string = "counter"
-	updatePath  string = "update"
-	updatesPath string = "updates"
-)
 
-type Metrics struct {
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	ID    string   `json:"id"`              // имя метрики
-}
+	agentIDHeader     string = "X-Agent-ID"
+	agentLabelsHeader string = "X-Agent-Labels"
+)
 
 type MetricsToSend struct {
 	MType string  `json:"type"`
@@ -36,65 +29,116 @@ type MetricsToSend struct {
 	Value float64 `json:"value,omitempty"`
 }
 
-func (a *Agent) saveMetrics() {
-	m := new(runtime.MemStats)
-	runtime.ReadMemStats(m)
-
+func (a *Agent) saveMetrics(metrics []collector.Metric) {
 	a.store.memLock.Lock()
+	defer a.store.memLock.Unlock()
+
+	for _, m := range metrics {
+		switch m.MType {
+		case collector.GaugeType:
+			a.store.Gauge[m.ID] = m.Value
+			a.aggregate(m.ID, m.Value)
+		case collector.CounterType:
+			a.store.Counter[m.ID] += m.Delta
+			a.store.Total[m.ID] += m.Delta
+		}
+	}
+}
 
-	a.store.Gauge["Alloc"] = float64(m.Alloc)
-	a.store.Gauge["BuckHashSys"] = float64(m.BuckHashSys)
-	a.store.Gauge["Frees"] = float64(m.Frees)
-	a.store.Gauge["GCCPUFraction"] = float64(m.GCCPUFraction)
-	a.store.Gauge["GCSys"] = float64(m.GCSys)
-	a.store.Gauge["HeapAlloc"] = float64(m.HeapAlloc)
-	a.store.Gauge["HeapIdle"] = float64(m.HeapIdle)
-	a.store.Gauge["HeapInuse"] = float64(m.HeapInuse)
-	a.store.Gauge["HeapObjects"] = float64(m.HeapObjects)
-	a.store.Gauge["HeapReleased"] = float64(m.HeapReleased)
-	a.store.Gauge["HeapSys"] = float64(m.HeapSys)
-	a.store.Gauge["LastGC"] = float64(m.LastGC)
-	a.store.Gauge["Lookups"] = float64(m.Lookups)
-	a.store.Gauge["MCacheInuse"] = float64(m.MCacheInuse)
-	a.store.Gauge["MCacheSys"] = float64(m.MCacheSys)
-	a.store.Gauge["MSpanInuse"] = float64(m.MSpanInuse)
-	a.store.Gauge["MSpanSys"] = float64(m.MSpanSys)
-	a.store.Gauge["Mallocs"] = float64(m.Mallocs)
-	a.store.Gauge["NextGC"] = float64(m.NextGC)
-	a.store.Gauge["NumForcedGC"] = float64(m.NumForcedGC)
-	a.store.Gauge["NumGC"] = float64(m.NumGC)
-	a.store.Gauge["OtherSys"] = float64(m.OtherSys)
-	a.store.Gauge["PauseTotalNs"] = float64(m.PauseTotalNs)
-	a.store.Gauge["StackInuse"] = float64(m.StackInuse)
-	a.store.Gauge["StackSys"] = float64(m.StackSys)
-	a.store.Gauge["Sys"] = float64(m.Sys)
-	a.store.Gauge["TotalAlloc"] = float64(m.TotalAlloc)
-	a.store.Gauge["RandomValue"] = rand.Float64()
-	a.store.Counter["PollCount"]++
+const outboxDepthMetric = "OutboxDepth"
 
-	a.store.memLock.Unlock()
+// sendMetrics reports every metric in a request of its own, the way the
+// agent reported before batches were added. Counter deltas go out with
+// whichever report takes them first.
+func (a *Agent) sendMetrics(ctx context.Context) error {
+	return a.report(ctx, true)
 }
 
-func (a *Agent) sendMetrics() {
-	for n, v := range a.store.Gauge {
-		err := a.sendData(v, n, GaugeType, http.MethodPost)
-		if err != nil {
-			a.logger.Info("an error occured sending gauge data", zap.Error(err))
+func (a *Agent) sendMetricsBatch(ctx context.Context) error {
+	return a.report(ctx, false)
+}
+
+// report hands the current metrics to every route at once, so a slow
+// destination does not hold back the others.
+func (a *Agent) report(ctx context.Context, each bool) error {
+	metrics := a.takeBatch()
+
+	errs := make([]error, len(a.routes))
+	wg := &sync.WaitGroup{}
+	for i, r := range a.routes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = a.deliver(ctx, r, metrics, each)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			a.markSent()
+			break
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	for n, v := range a.store.Counter {
-		err := a.sendData(v, n, CounterType, http.MethodPost)
-		if err != nil {
-			a.logger.Info("an error occured sending counter data", zap.Error(err))
+// deliver sends the batch to the route. Only batch reports go through the
+// outbox; per-metric reports come every report interval as well, and what
+// they fail to send waits in memory for the next batch, so the outbox does
+// not fill up twice as fast.
+func (a *Agent) deliver(ctx context.Context, r *route, batch []MetricsToSend, each bool) error {
+	batch = mergeBatches(r.pending, batch)
+	r.pending = nil
+
+	if r.outbox == nil || each {
+		err := r.send(ctx, batch, each)
+		if err == nil {
+			return nil
+		}
+		if client.IsPermanent(err) {
+			return fmt.Errorf("server rejected a batch: %w", err)
+		}
+		r.pending = unsent(err, batch)
+		return fmt.Errorf("an error occured sending data in a batch: %w", err)
+	}
+
+	err := r.outbox.Replay(func(queued []MetricsToSend) error {
+		err := r.send(ctx, queued, false)
+		if client.IsPermanent(err) {
+			a.logger.Error("server rejected a queued batch, dropping it", zap.Error(err))
+			return nil
+		}
+		return err
+	})
+	if err == nil {
+		err = r.send(ctx, batch, each)
+		if err == nil {
+			a.updateOutboxDepth(r)
+			return nil
+		}
+		if client.IsPermanent(err) {
+			a.updateOutboxDepth(r)
+			return fmt.Errorf("server rejected a batch: %w", err)
 		}
+		batch = unsent(err, batch)
 	}
 
-	a.store.Counter["PollCount"] = 0
+	if pushErr := r.outbox.Push(batch); pushErr != nil {
+		r.pending = batch
+		return fmt.Errorf("failed to queue unsent batch: %w", errors.Join(err, pushErr))
+	}
+	a.updateOutboxDepth(r)
+	return fmt.Errorf("an error occured sending data in a batch, queued it to the outbox: %w", err)
 }
 
-func (a *Agent) sendMetricsBatch() error {
-	metrics := []MetricsToSend{}
+// takeBatch snapshots the store and resets counter deltas: from here on the
+// batch owns them, whether it gets delivered now or via the outbox.
+func (a *Agent) takeBatch() []MetricsToSend {
+	a.store.memLock.Lock()
+	defer a.store.memLock.Unlock()
+
+	metrics := make([]MetricsToSend, 0, len(a.store.Gauge)+len(a.store.Counter))
 
 	for n, v := range a.store.Gauge {
 		m := MetricsToSend{Value: v, Delta: 0, MType: GaugeType, ID: n}
@@ -104,120 +148,63 @@ func (a *Agent) sendMetricsBatch() error {
 	for n, v := range a.store.Counter {
 		m := MetricsToSend{Value: 0, Delta: v, MType: CounterType, ID: n}
 		metrics = append(metrics, m)
+		a.store.Counter[n] = 0
 	}
 
-	err := a.sendBatch(metrics, http.MethodPost)
-	if err != nil {
-		return fmt.Errorf("an error occured sending data in a batch: %w", err)
-	}
-	a.store.Counter["PollCount"] = 0
-
-	return nil
-}
-
-func (a *Agent) sendData(v any, n string, mType string, method string) error {
-	var metric = Metrics{}
-
-	switch mType {
-	case GaugeType:
-		switch i := v.(type) {
-		case float64:
-			metric = Metrics{ID: n, MType: GaugeType, Value: &i}
-		default:
-			return errors.New(GaugeType + " is not float64")
+	for n, agg := range a.store.aggregates {
+		if agg.count == 0 {
+			continue
 		}
-	case CounterType:
-		switch i := v.(type) {
-		case int64:
-			metric = Metrics{ID: n, MType: CounterType, Delta: &i}
-		default:
-			return errors.New(CounterType + " is not int64")
+		for _, stat := range agg.stats {
+			m := MetricsToSend{Value: agg.value(stat), MType: GaugeType, ID: derivedID(n, stat)}
+			metrics = append(metrics, m)
 		}
+		agg.reset()
 	}
 
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(metric)
-
-	if err != nil {
-		return fmt.Errorf("failed to JSON encode gauge metric: %w", err)
-	}
-
-	sendURL, err := url.JoinPath("http://", a.cfg.Host, updatePath, "/")
-	if err != nil {
-		return fmt.Errorf("failed to join path parts for gauge JSON POST URL: %w", err)
-	}
-
-	req, err := http.NewRequest(method, sendURL, &buf)
-	if err != nil {
-		return fmt.Errorf("failed to create a request: %w", err)
-	}
-	req.Close = true
-
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Set("Content-Length", strconv.Itoa(buf.Len()))
-
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to do a request, server is probably down:  %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP status code is not 200 OK: %w", err)
-	}
-
-	_, err = io.Copy(io.Discard, resp.Body)
-	if err != nil {
-		return fmt.Errorf("error copying response body: %w", err)
-	}
-
-	err = resp.Body.Close()
-	if err != nil {
-		a.logger.Info("error closing response body:", zap.Error(err))
-		return fmt.Errorf("error closing response body: %w", err)
-	}
-
-	return nil
+	return metrics
 }
 
-func (a *Agent) sendBatch(batch []MetricsToSend, method string) error {
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(batch)
-	if err != nil {
-		return fmt.Errorf("failed to JSON encode gauge metric: %w", err)
-	}
-
-	sendURL, err := url.JoinPath("http://", a.cfg.Host, updatesPath, "/")
-	if err != nil {
-		return fmt.Errorf("failed to join path parts for gauge JSON POST URL: %w", err)
-	}
-
-	req, err := http.NewRequest(method, sendURL, &buf)
-	if err != nil {
-		return fmt.Errorf("failed to create a request: %w", err)
+// aggregate must be called with memLock held.
+func (a *Agent) aggregate(name string, value float64) {
+	agg, ok := a.store.aggregates[name]
+	if !ok {
+		stats := a.aggregateStats(name)
+		if len(stats) == 0 {
+			return
+		}
+		agg = &gaugeAggregate{stats: stats}
+		a.store.aggregates[name] = agg
 	}
-	req.Close = true
-
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Set("Content-Length", strconv.Itoa(buf.Len()))
+	agg.add(value)
+}
 
-	resp, err := a.client.Do(req)
+func (a *Agent) updateOutboxDepth(r *route) {
+	depth, err := r.outbox.Len()
 	if err != nil {
-		return fmt.Errorf("failed to do a request, server is probably down:  %w", err)
+		a.logger.Info("failed to get outbox depth", zap.Error(err))
+		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP status code is not 200 OK: %w", err)
-	}
+	a.store.memLock.Lock()
+	a.store.Gauge[r.depthID] = float64(depth)
+	a.store.memLock.Unlock()
+}
 
-	_, err = io.Copy(io.Discard, resp.Body)
-	if err != nil {
-		return fmt.Errorf("error copying response body: %w", err)
+// identityOptions adds the agent ID and static labels to every request, so
+// the server can tell agents reporting the same metric names apart.
+func identityOptions(cfg config.AgentCfg) []client.Option {
+	opts := make([]client.Option, 0, 2)
+	if cfg.ID != "" {
+		opts = append(opts, client.WithHeader(agentIDHeader, cfg.ID))
 	}
 
-	err = resp.Body.Close()
-	if err != nil {
-		return fmt.Errorf("error closing response body: %w", err)
+	if len(cfg.Labels) > 0 {
+		values := url.Values{}
+		for k, v := range cfg.Labels {
+			values.Set(k, v)
+		}
+		opts = append(opts, client.WithHeader(agentLabelsHeader, values.Encode()))
 	}
-
-	return nil
+	return opts
 }
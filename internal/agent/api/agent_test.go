@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/agent/collector"
+	"go-yandex-metrics/internal/config"
+)
+
+type tickCollector struct{}
+
+func (tickCollector) Name() string {
+	return "tick"
+}
+
+func (tickCollector) Interval() time.Duration {
+	return time.Hour
+}
+
+func (tickCollector) Collect(ctx context.Context) ([]collector.Metric, error) {
+	return []collector.Metric{collector.Counter("Ticks", 1)}, nil
+}
+
+func TestAgent_StartFlushesOnShutdown(t *testing.T) {
+	srv := &fakeServer{mu: &sync.Mutex{}, received: make(map[string]int64)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	const shutdownTimeout = 2 * time.Second
+	retryMax := 0
+	cfg := config.AgentCfg{
+		ReportInterval:  3600,
+		ShutdownTimeout: config.Duration{Duration: shutdownTimeout},
+		Delivery:        config.DeliveryFanout,
+		Destinations: []config.DestinationCfg{
+			{Name: "default", Host: ts.URL, Mode: config.ModeBatch, RetryMax: &retryMax},
+		},
+	}
+	a := newTestAgent(t, cfg)
+	a.collectors = []collector.Collector{tickCollector{}}
+	a.saveCounter("PollCount", 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Start(ctx)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Equal(t, err, nil)
+	case <-time.After(shutdownTimeout):
+		t.Fatal("agent did not stop within the shutdown timeout")
+	}
+
+	// Последний батч уходит уже после отмены контекста.
+	assert.Equal(t, srv.count("PollCount"), int64(3))
+	assert.Equal(t, srv.count("Ticks"), int64(1))
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+
+	"go-yandex-metrics/internal/labels"
+)
+
+const aggregateAll = "*"
+
+const (
+	statMin   = "min"
+	statMax   = "max"
+	statAvg   = "avg"
+	statCount = "count"
+	statLast  = "last"
+)
+
+// gaugeAggregate accumulates the samples of one gauge polled during a
+// report window, so that spikes between two reports are not lost.
+type gaugeAggregate struct {
+	stats []string
+	min   float64
+	max   float64
+	sum   float64
+	last  float64
+	count int
+}
+
+func (g *gaugeAggregate) add(value float64) {
+	if g.count == 0 || value < g.min {
+		g.min = value
+	}
+	if g.count == 0 || value > g.max {
+		g.max = value
+	}
+	g.sum += value
+	g.last = value
+	g.count++
+}
+
+func (g *gaugeAggregate) value(stat string) float64 {
+	switch stat {
+	case statMin:
+		return g.min
+	case statMax:
+		return g.max
+	case statAvg:
+		return g.sum / float64(g.count)
+	case statCount:
+		return float64(g.count)
+	default:
+		return g.last
+	}
+}
+
+func (g *gaugeAggregate) reset() {
+	*g = gaugeAggregate{stats: g.stats}
+}
+
+func validateAggregation(rules map[string][]string) error {
+	for name, stats := range rules {
+		for _, stat := range stats {
+			switch stat {
+			case statMin, statMax, statAvg, statCount, statLast:
+			default:
+				return fmt.Errorf("unknown aggregation %q for gauge %q", stat, name)
+			}
+		}
+	}
+	return nil
+}
+
+// aggregateStats returns the statistics configured for the gauge, a rule for
+// the exact name taking precedence over the "*" rule.
+func (a *Agent) aggregateStats(name string) []string {
+	if stats, ok := a.cfg.Aggregate[name]; ok {
+		return stats
+	}
+	return a.cfg.Aggregate[aggregateAll]
+}
+
+// derivedID appends the statistic to the metric name, keeping labels intact:
+// HeapAlloc{pid="1"} becomes HeapAlloc.max{pid="1"}.
+func derivedID(id, stat string) string {
+	name, lbls, err := labels.Parse(id)
+	if err != nil {
+		return id + "." + stat
+	}
+	return labels.Format(name+"."+stat, lbls)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestGaugeAggregate(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   map[string]float64
+	}{
+		{
+			name:   "single sample",
+			values: []float64{5},
+			want:   map[string]float64{statMin: 5, statMax: 5, statAvg: 5, statCount: 1, statLast: 5},
+		},
+		{
+			name:   "spike between reports",
+			values: []float64{2, 10, -4, 4},
+			want:   map[string]float64{statMin: -4, statMax: 10, statAvg: 3, statCount: 4, statLast: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &gaugeAggregate{stats: []string{statMin, statMax, statAvg, statCount, statLast}}
+			for _, v := range tt.values {
+				g.add(v)
+			}
+			for stat, want := range tt.want {
+				assert.Equal(t, g.value(stat), want)
+			}
+
+			g.reset()
+			assert.Equal(t, g.count, 0)
+			assert.Equal(t, len(g.stats), 5)
+
+			// После сброса окно начинается заново, а не с прошлых min и max.
+			g.add(7)
+			assert.Equal(t, g.value(statMin), float64(7))
+			assert.Equal(t, g.value(statMax), float64(7))
+		})
+	}
+}
+
+func TestValidateAggregation(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   map[string][]string
+		wantErr bool
+	}{
+		{
+			name:  "no rules",
+			rules: nil,
+		},
+		{
+			name:  "known statistics",
+			rules: map[string][]string{"*": {statMax}, "HeapAlloc": {statMin, statMax, statAvg, statCount, statLast}},
+		},
+		{
+			name:    "unknown statistic",
+			rules:   map[string][]string{"HeapAlloc": {statMax, "p99"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAggregation(tt.rules)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
+
+func TestDerivedID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		stat string
+		want string
+	}{
+		{
+			name: "plain name",
+			id:   "HeapAlloc",
+			stat: statMax,
+			want: "HeapAlloc.max",
+		},
+		{
+			name: "labels stay after the statistic",
+			id:   `HeapAlloc{pid="1"}`,
+			stat: statAvg,
+			want: `HeapAlloc.avg{pid="1"}`,
+		},
+		{
+			name: "unparsable ID gets the suffix",
+			id:   `HeapAlloc{pid=`,
+			stat: statMin,
+			want: `HeapAlloc{pid=.min`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, derivedID(tt.id, tt.stat), tt.want)
+		})
+	}
+}
+
+func TestAgent_TakeBatchAggregates(t *testing.T) {
+	a := newTestAgent(t, config.AgentCfg{
+		Aggregate: map[string][]string{"*": {statMax}, "HeapAlloc": {statMin, statCount}},
+	})
+
+	a.store.memLock.Lock()
+	for _, v := range []float64{3, 1, 2} {
+		a.aggregate("HeapAlloc", v)
+		a.aggregate("Sys", v*10)
+	}
+	a.store.memLock.Unlock()
+
+	got := make(map[string]float64)
+	for _, m := range a.takeBatch() {
+		got[m.ID] = m.Value
+	}
+	assert.Equal(t, got, map[string]float64{"HeapAlloc.min": 1, "HeapAlloc.count": 3, "Sys.max": 30})
+
+	assert.Equal(t, len(a.takeBatch()), 0)
+}
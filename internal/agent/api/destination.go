@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/labels"
+	"go-yandex-metrics/pkg/client"
+)
+
+const (
+	deliveryUpMetric     = "DeliveryUp"
+	deliveryErrorsMetric = "DeliveryErrors"
+)
+
+// destination is one server the agent reports to.
+type destination struct {
+	sender   *client.Sender
+	name     string
+	upID     string
+	errorsID string
+	single   bool
+	healthy  bool
+}
+
+func newDestination(cfg config.AgentCfg, d config.DestinationCfg) (*destination, error) {
+	opts := []client.Option{
+		client.WithRetry(*d.RetryMax, d.RetryWaitMin.Duration, d.RetryWaitMax.Duration),
+	}
+	if d.Key != "" {
+		opts = append(opts, client.WithKey(d.Key))
+	}
+	if d.Gzip {
+		opts = append(opts, client.WithGzip())
+	}
+	opts = append(opts, identityOptions(cfg)...)
+
+	sender, err := client.NewSender(d.Host, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sender for destination %q: %w", d.Name, err)
+	}
+
+	lbls := labels.Labels{"destination": d.Name}
+	return &destination{
+		sender:   sender,
+		name:     d.Name,
+		upID:     labels.Format(deliveryUpMetric, lbls),
+		errorsID: labels.Format(deliveryErrorsMetric, lbls),
+		single:   d.Mode == config.ModeSingle,
+		healthy:  true,
+	}, nil
+}
+
+// send delivers batch in one request or, in single mode or when each is set,
+// one request per metric. On a partial failure the error is an *unsentError
+// with the metrics the server rejected and the ones it never got, so what
+// already went out is not sent twice.
+func (d *destination) send(ctx context.Context, batch []MetricsToSend, each bool) error {
+	metrics := toClientMetrics(batch)
+
+	if !d.single && !each {
+		if err := d.sender.SendBatch(ctx, metrics); err != nil {
+			return fmt.Errorf("failed to send a batch to %s: %w", d.name, err)
+		}
+		return nil
+	}
+
+	var rejected []MetricsToSend
+	var rejectedErrs []error
+	for i, m := range metrics {
+		err := d.sender.Send(ctx, m)
+		if err == nil {
+			continue
+		}
+		if client.IsPermanent(err) {
+			rejected = append(rejected, batch[i])
+			rejectedErrs = append(rejectedErrs, fmt.Errorf("%s rejected %s %s: %w", d.name, m.MType, m.ID, err))
+			continue
+		}
+		return &unsentError{
+			rejected: rejected,
+			unsent:   batch[i:],
+			err:      fmt.Errorf("failed to send %s %s to %s: %w", m.MType, m.ID, d.name, err),
+		}
+	}
+
+	if len(rejected) == 0 {
+		return nil
+	}
+	return &unsentError{rejected: rejected, err: errors.Join(rejectedErrs...)}
+}
+
+// unsentError is a failed send that delivered only part of a batch: the
+// server rejected some metrics and did not get to the unsent ones.
+type unsentError struct {
+	err      error
+	rejected []MetricsToSend
+	unsent   []MetricsToSend
+}
+
+func (e *unsentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unsentError) Unwrap() error {
+	return e.err
+}
+
+// unsent returns the part of batch a send that failed with err did not
+// deliver.
+func unsent(err error, batch []MetricsToSend) []MetricsToSend {
+	var unsentErr *unsentError
+	if errors.As(err, &unsentErr) {
+		return unsentErr.unsent
+	}
+	return batch
+}
+
+// remainder returns the part of batch the next destination of a failover
+// chain gets: what the failed one rejected or did not get to.
+func remainder(err error, batch []MetricsToSend) []MetricsToSend {
+	var unsentErr *unsentError
+	if errors.As(err, &unsentErr) {
+		return append(slices.Clip(unsentErr.rejected), unsentErr.unsent...)
+	}
+	return batch
+}
+
+// route gets one copy of every batch: a single destination in fan-out mode or
+// the whole chain in failover mode. Batches it fails to deliver wait in its
+// outbox, or in memory when the outbox is disabled.
+type route struct {
+	send    func(ctx context.Context, batch []MetricsToSend, each bool) error
+	outbox  *Outbox
+	depthID string
+	pending []MetricsToSend
+}
+
+func (a *Agent) newRoutes() ([]*route, error) {
+	if a.cfg.Delivery == config.DeliveryFailover || len(a.destinations) == 1 {
+		r := &route{send: a.sendFailover, depthID: outboxDepthMetric}
+		if a.cfg.OutboxPath != "" {
+			outbox, err := NewOutbox(a.cfg.OutboxPath, a.cfg.OutboxSize)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create outbox: %w", err)
+			}
+			r.outbox = outbox
+		}
+		return []*route{r}, nil
+	}
+
+	routes := make([]*route, 0, len(a.destinations))
+	for _, d := range a.destinations {
+		r := &route{
+			send: func(ctx context.Context, batch []MetricsToSend, each bool) error {
+				return a.sendTo(ctx, d, batch, each)
+			},
+			depthID: labels.Format(outboxDepthMetric, labels.Labels{"destination": d.name}),
+		}
+		if a.cfg.OutboxPath != "" {
+			outbox, err := NewOutbox(filepath.Join(a.cfg.OutboxPath, d.name), a.cfg.OutboxSize)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create outbox for destination %q: %w", d.name, err)
+			}
+			r.outbox = outbox
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// sendFailover tries destinations in order and stops at the first one that
+// takes the batch. It only gives up for good when every destination rejects
+// it.
+func (a *Agent) sendFailover(ctx context.Context, batch []MetricsToSend, each bool) error {
+	var transient, rejected []error
+	for _, d := range a.destinations {
+		err := a.sendTo(ctx, d, batch, each)
+		if err == nil {
+			return nil
+		}
+		if client.IsPermanent(err) {
+			rejected = append(rejected, err)
+		} else {
+			transient = append(transient, err)
+		}
+		batch = remainder(err, batch)
+	}
+
+	if len(transient) == 0 {
+		return errors.Join(rejected...)
+	}
+	return &unsentError{unsent: batch, err: errors.Join(transient...)}
+}
+
+func (a *Agent) sendTo(ctx context.Context, d *destination, batch []MetricsToSend, each bool) error {
+	err := d.send(ctx, batch, each)
+	a.recordDelivery(d, err)
+	return err
+}
+
+// recordDelivery reports the destination status as DeliveryUp and
+// DeliveryErrors, and logs when it goes down or comes back.
+func (a *Agent) recordDelivery(d *destination, err error) {
+	up := float64(1)
+	if err != nil {
+		up = 0
+	}
+
+	a.store.memLock.Lock()
+	a.store.Gauge[d.upID] = up
+	if err != nil {
+		a.store.Counter[d.errorsID]++
+		a.store.Total[d.errorsID]++
+	}
+	a.store.memLock.Unlock()
+
+	switch {
+	case err != nil:
+		a.logger.Error("failed to deliver metrics", zap.String("destination", d.name), zap.Error(err))
+		d.healthy = false
+	case !d.healthy:
+		a.logger.Info("delivering metrics again", zap.String("destination", d.name))
+		d.healthy = true
+	}
+}
+
+func toClientMetrics(batch []MetricsToSend) []client.Metrics {
+	metrics := make([]client.Metrics, 0, len(batch))
+	for _, m := range batch {
+		switch m.MType {
+		case CounterType:
+			metrics = append(metrics, client.CounterMetric(m.ID, m.Delta))
+		default:
+			metrics = append(metrics, client.GaugeMetric(m.ID, m.Value))
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/pkg/client"
+)
+
+type fakeServer struct {
+	mu       *sync.Mutex
+	received map[string]int64
+	reject   map[string]bool
+	down     bool
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var batch []client.Metrics
+	if strings.HasSuffix(r.URL.Path, "/updates/") {
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else {
+		var m client.Metrics
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batch = append(batch, m)
+	}
+
+	for _, m := range batch {
+		if s.reject[m.ID] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	for _, m := range batch {
+		if m.Delta != nil {
+			s.received[m.ID] += *m.Delta
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *fakeServer) setDown(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+}
+
+func (s *fakeServer) count(id string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received[id]
+}
+
+func TestAgent_Delivery(t *testing.T) {
+	tests := []struct {
+		name        string
+		delivery    string
+		mode        string
+		wantPrimary int64
+		wantStandby int64
+	}{
+		{
+			name:        "fan-out sends to every destination and catches up the one that was down",
+			delivery:    config.DeliveryFanout,
+			mode:        config.ModeBatch,
+			wantPrimary: 3,
+			wantStandby: 3,
+		},
+		{
+			name:        "failover sends to the standby only while the primary is down",
+			delivery:    config.DeliveryFailover,
+			mode:        config.ModeSingle,
+			wantPrimary: 1,
+			wantStandby: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &fakeServer{mu: &sync.Mutex{}, received: make(map[string]int64), down: true}
+			standby := &fakeServer{mu: &sync.Mutex{}, received: make(map[string]int64)}
+			primarySrv := httptest.NewServer(primary)
+			defer primarySrv.Close()
+			standbySrv := httptest.NewServer(standby)
+			defer standbySrv.Close()
+
+			retryMax := 0
+			cfg := config.AgentCfg{
+				Delivery: tt.delivery,
+				Destinations: []config.DestinationCfg{
+					{Name: "primary", Host: primarySrv.URL, Mode: tt.mode, RetryMax: &retryMax},
+					{Name: "standby", Host: standbySrv.URL, Mode: tt.mode, RetryMax: &retryMax},
+				},
+			}
+			a := newTestAgent(t, cfg)
+
+			a.saveCounter("PollCount", 2)
+			_ = a.sendMetricsBatch(context.Background())
+
+			primary.setDown(false)
+			a.saveCounter("PollCount", 1)
+			if err := a.sendMetricsBatch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, primary.count("PollCount"), tt.wantPrimary)
+			assert.Equal(t, standby.count("PollCount"), tt.wantStandby)
+			assert.Equal(t, a.store.Total[`DeliveryErrors{destination="primary"}`], int64(1))
+		})
+	}
+}
+
+func TestAgent_FailoverPassesOnRemainder(t *testing.T) {
+	primary := &fakeServer{mu: &sync.Mutex{}, received: make(map[string]int64), reject: map[string]bool{"Bad": true}}
+	standby := &fakeServer{mu: &sync.Mutex{}, received: make(map[string]int64)}
+	primarySrv := httptest.NewServer(primary)
+	defer primarySrv.Close()
+	standbySrv := httptest.NewServer(standby)
+	defer standbySrv.Close()
+
+	retryMax := 0
+	a := newTestAgent(t, config.AgentCfg{
+		Delivery: config.DeliveryFailover,
+		Destinations: []config.DestinationCfg{
+			{Name: "primary", Host: primarySrv.URL, Mode: config.ModeSingle, RetryMax: &retryMax},
+			{Name: "standby", Host: standbySrv.URL, Mode: config.ModeSingle, RetryMax: &retryMax},
+		},
+	})
+
+	a.saveCounter("PollCount", 2)
+	a.saveCounter("Bad", 5)
+	if err := a.sendMetricsBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Резервный сервер получает только то, что отверг основной.
+	assert.Equal(t, primary.count("PollCount"), int64(2))
+	assert.Equal(t, standby.count("PollCount"), int64(0))
+	assert.Equal(t, standby.count("Bad"), int64(5))
+}
+
+func TestAgent_SendMetrics(t *testing.T) {
+	mu := &sync.Mutex{}
+	paths := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	retryMax := 0
+	a := newTestAgent(t, config.AgentCfg{
+		Delivery:     config.DeliveryFanout,
+		Destinations: []config.DestinationCfg{{Name: "default", Host: srv.URL, Mode: config.ModeBatch, RetryMax: &retryMax}},
+	})
+	a.saveCounter("PollCount", 1)
+	a.store.Gauge["Alloc"] = 1
+
+	if err := a.sendMetrics(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sendMetricsBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, paths["/update/"], 2)
+	assert.Equal(t, paths["/updates/"], 1)
+}
+
+func newTestAgent(t *testing.T, cfg config.AgentCfg) *Agent {
+	t.Helper()
+
+	store, err := NewAgentMemStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Agent{
+		started:  time.Now(),
+		lastSent: &atomic.Int64{},
+		logger:   zap.NewNop(),
+		store:    store,
+		cfg:      cfg,
+	}
+	for _, d := range cfg.Destinations {
+		d.RetryWaitMin.Duration = time.Millisecond
+		d.RetryWaitMax.Duration = time.Millisecond
+		dest, err := newDestination(cfg, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.destinations = append(a.destinations, dest)
+	}
+
+	a.routes, err = a.newRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func (a *Agent) saveCounter(id string, delta int64) {
+	a.store.memLock.Lock()
+	a.store.Counter[id] += delta
+	a.store.memLock.Unlock()
+}
+
+func TestAgent_PerMetricReportsSkipOutbox(t *testing.T) {
+	srv := httptest.NewServer(&fakeServer{mu: &sync.Mutex{}, received: make(map[string]int64), down: true})
+	defer srv.Close()
+
+	retryMax := 0
+	a := newTestAgent(t, config.AgentCfg{
+		OutboxPath: t.TempDir(),
+		OutboxSize: 10,
+		Delivery:   config.DeliveryFanout,
+		Destinations: []config.DestinationCfg{
+			{Name: "default", Host: srv.URL, Mode: config.ModeBatch, RetryMax: &retryMax},
+		},
+	})
+
+	a.saveCounter("PollCount", 1)
+	assert.NotEqual(t, a.sendMetrics(context.Background()), nil)
+	a.saveCounter("PollCount", 1)
+	assert.NotEqual(t, a.sendMetricsBatch(context.Background()), nil)
+
+	// За один интервал в очередь попадает одна пачка, и в ней обе дельты.
+	r := a.routes[0]
+	depth, err := r.outbox.Len()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, depth, 1)
+	assert.Equal(t, len(r.pending), 0)
+
+	var queued int64
+	err = r.outbox.Replay(func(batch []MetricsToSend) error {
+		for _, m := range batch {
+			if m.ID == "PollCount" {
+				queued += m.Delta
+			}
+		}
+		return nil
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, queued, int64(2))
+}
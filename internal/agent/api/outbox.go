@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const outboxFileExt = ".json"
+
+// Outbox is a bounded on-disk queue of batches the agent failed to deliver,
+// one file per batch named after a growing sequence number.
+type Outbox struct {
+	mu       *sync.Mutex
+	dir      string
+	maxSize  int
+	sequence uint64
+}
+
+func NewOutbox(dir string, maxSize int) (*Outbox, error) {
+	if maxSize < 1 {
+		return nil, fmt.Errorf("outbox size must be positive, got %d", maxSize)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	o := &Outbox{
+		mu:      &sync.Mutex{},
+		dir:     dir,
+		maxSize: maxSize,
+	}
+
+	entries, err := o.entries()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		o.sequence = entries[len(entries)-1]
+	}
+
+	return o, nil
+}
+
+// Push stores a batch at the tail of the queue. When the queue is full the two
+// oldest batches are merged into one, so counter deltas are never dropped.
+func (o *Outbox) Push(batch []MetricsToSend) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.sequence++
+	if err := o.write(o.sequence, batch); err != nil {
+		return err
+	}
+
+	entries, err := o.entries()
+	if err != nil {
+		return err
+	}
+
+	for len(entries) > o.maxSize {
+		if err := o.mergeOldest(entries[0], entries[1]); err != nil {
+			return err
+		}
+		entries = entries[1:]
+	}
+
+	return nil
+}
+
+// Replay sends queued batches oldest first and removes each one once it is
+// delivered. It stops at the first failure, leaving the rest in place; a
+// partly delivered batch is cut down to what is left.
+func (o *Outbox) Replay(send func([]MetricsToSend) error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries, err := o.entries()
+	if err != nil {
+		return err
+	}
+
+	for _, seq := range entries {
+		batch, err := o.read(seq)
+		if err != nil {
+			return err
+		}
+
+		if err := send(batch); err != nil {
+			if rest := unsent(err, batch); len(rest) < len(batch) {
+				err = errors.Join(err, o.write(seq, rest))
+			}
+			return fmt.Errorf("failed to replay outbox batch %d: %w", seq, err)
+		}
+
+		if err := os.Remove(o.path(seq)); err != nil {
+			return fmt.Errorf("failed to remove delivered outbox batch: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Outbox) Len() (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries, err := o.entries()
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+func (o *Outbox) mergeOldest(first, second uint64) error {
+	older, err := o.read(first)
+	if err != nil {
+		return err
+	}
+	newer, err := o.read(second)
+	if err != nil {
+		return err
+	}
+
+	if err := o.write(second, mergeBatches(older, newer)); err != nil {
+		return err
+	}
+
+	if err := os.Remove(o.path(first)); err != nil {
+		return fmt.Errorf("failed to remove merged outbox batch: %w", err)
+	}
+	return nil
+}
+
+// mergeBatches folds newer into older: gauges take the newer value and
+// counter deltas are summed.
+func mergeBatches(older, newer []MetricsToSend) []MetricsToSend {
+	merged := make([]MetricsToSend, 0, len(older)+len(newer))
+	index := make(map[string]int)
+
+	for _, batch := range [][]MetricsToSend{older, newer} {
+		for _, m := range batch {
+			key := m.MType + ":" + m.ID
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(merged)
+				merged = append(merged, m)
+				continue
+			}
+
+			switch m.MType {
+			case CounterType:
+				merged[i].Delta += m.Delta
+			default:
+				merged[i].Value = m.Value
+			}
+		}
+	}
+
+	return merged
+}
+
+func (o *Outbox) entries() ([]uint64, error) {
+	files, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox directory: %w", err)
+	}
+
+	entries := make([]uint64, 0, len(files))
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, outboxFileExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, outboxFileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, seq)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i] < entries[j] })
+	return entries, nil
+}
+
+func (o *Outbox) path(seq uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d%s", seq, outboxFileExt))
+}
+
+func (o *Outbox) read(seq uint64) ([]MetricsToSend, error) {
+	data, err := os.ReadFile(o.path(seq))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox batch: %w", err)
+	}
+
+	var batch []MetricsToSend
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outbox batch %d: %w", seq, err)
+	}
+	return batch, nil
+}
+
+func (o *Outbox) write(seq uint64, batch []MetricsToSend) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox batch: %w", err)
+	}
+
+	tmp := o.path(seq) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write outbox batch: %w", err)
+	}
+
+	if err := os.Rename(tmp, o.path(seq)); err != nil {
+		return errors.Join(fmt.Errorf("failed to commit outbox batch: %w", err), os.Remove(tmp))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-playground/assert"
+)
+
+func TestOutbox_PushReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		batches [][]MetricsToSend
+		want    [][]MetricsToSend
+		maxSize int
+	}{
+		{
+			name: "batches are replayed in order",
+			batches: [][]MetricsToSend{
+				{{MType: GaugeType, ID: "Alloc", Value: 1}},
+				{{MType: GaugeType, ID: "Alloc", Value: 2}},
+			},
+			want: [][]MetricsToSend{
+				{{MType: GaugeType, ID: "Alloc", Value: 1}},
+				{{MType: GaugeType, ID: "Alloc", Value: 2}},
+			},
+			maxSize: 10,
+		},
+		{
+			name: "full outbox merges counters and keeps the latest gauge",
+			batches: [][]MetricsToSend{
+				{{MType: CounterType, ID: "PollCount", Delta: 5}, {MType: GaugeType, ID: "Alloc", Value: 1}},
+				{{MType: CounterType, ID: "PollCount", Delta: 3}, {MType: GaugeType, ID: "Alloc", Value: 2}},
+				{{MType: CounterType, ID: "PollCount", Delta: 1}},
+			},
+			want: [][]MetricsToSend{
+				{{MType: CounterType, ID: "PollCount", Delta: 8}, {MType: GaugeType, ID: "Alloc", Value: 2}},
+				{{MType: CounterType, ID: "PollCount", Delta: 1}},
+			},
+			maxSize: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := NewOutbox(t.TempDir(), tt.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, b := range tt.batches {
+				if err := o.Push(b); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got [][]MetricsToSend
+			err = o.Replay(func(batch []MetricsToSend) error {
+				got = append(got, batch)
+				return nil
+			})
+			assert.Equal(t, err, nil)
+			assert.Equal(t, got, tt.want)
+
+			depth, err := o.Len()
+			assert.Equal(t, err, nil)
+			assert.Equal(t, depth, 0)
+		})
+	}
+}
+
+func TestOutbox_ReplayStopsOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	o, err := NewOutbox(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []float64{1, 2, 3} {
+		if err := o.Push([]MetricsToSend{{MType: GaugeType, ID: "Alloc", Value: v}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := 0
+	err = o.Replay(func(batch []MetricsToSend) error {
+		if sent == 1 {
+			return errors.New("server is down")
+		}
+		sent++
+		return nil
+	})
+	assert.NotEqual(t, err, nil)
+
+	reopened, err := NewOutbox(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth, err := reopened.Len()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, depth, 2)
+}
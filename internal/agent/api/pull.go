@@ -0,0 +1,269 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/labels"
+	logger "go-yandex-metrics/internal/server/middleware"
+)
+
+const (
+	contentTypeStr  = "Content-Type"
+	applicationJSON = "application/json"
+	textPlainProm   = "text/plain; version=0.0.4; charset=utf-8"
+	// healthyReports is how many report intervals may pass without a
+	// successful send before /healthz starts failing.
+	healthyReports = 3
+)
+
+type healthStatus struct {
+	LastSuccess *time.Time `json:"last_success"`
+	Status      string     `json:"status"`
+}
+
+func (a *Agent) pullRoutes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(logger.Logger(a.logger))
+
+	r.Get("/metrics", a.PrometheusHandler)
+	r.Get("/metrics/json", a.JSONHandler)
+	r.Get("/healthz", a.HealthHandler)
+
+	return r
+}
+
+func (a *Agent) startPullServer() *http.Server {
+	server := &http.Server{
+		Addr:              a.cfg.PullAddress,
+		Handler:           a.pullRoutes(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		a.logger.Info("starting agent pull endpoint", zap.String("address", a.cfg.PullAddress))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.logger.Error("agent pull endpoint has stopped", zap.Error(err))
+		}
+	}()
+
+	return server
+}
+
+func (a *Agent) JSONHandler(w http.ResponseWriter, r *http.Request) {
+	a.store.memLock.Lock()
+	data, err := json.Marshal(a.store)
+	a.store.memLock.Unlock()
+	if err != nil {
+		a.logger.Info("failed to marshal agent storage", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeStr, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		a.logger.Info("failed to write agent storage", zap.Error(err))
+	}
+}
+
+// PrometheusHandler exposes gauges as they are and counters as totals since
+// the agent started, because Prometheus expects counters to be cumulative.
+func (a *Agent) PrometheusHandler(w http.ResponseWriter, r *http.Request) {
+	e := newExposition()
+	a.store.memLock.Lock()
+	for _, id := range sortedKeys(a.store.Gauge) {
+		e.add(GaugeType, id, strconv.FormatFloat(a.store.Gauge[id], 'g', -1, 64))
+	}
+	for _, id := range sortedKeys(a.store.Total) {
+		e.add(CounterType, id, strconv.FormatInt(a.store.Total[id], 10))
+	}
+	a.store.memLock.Unlock()
+
+	if len(e.skipped) > 0 {
+		a.logger.Info("metrics clash with others after renaming for Prometheus, skipped them",
+			zap.Strings("ids", e.skipped))
+	}
+
+	var buf bytes.Buffer
+	e.write(&buf)
+
+	w.Header().Set(contentTypeStr, textPlainProm)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		a.logger.Info("failed to write agent metrics", zap.Error(err))
+	}
+}
+
+func (a *Agent) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "ok"}
+	code := http.StatusOK
+
+	deadline := healthyReports * time.Duration(a.cfg.ReportInterval) * time.Second
+	last := a.lastSuccess()
+	if !last.IsZero() {
+		status.LastSuccess = &last
+	} else {
+		last = a.started
+	}
+	if time.Since(last) > deadline {
+		status.Status = "stale"
+		code = http.StatusServiceUnavailable
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		a.logger.Info("failed to marshal health status", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeStr, applicationJSON)
+	w.WriteHeader(code)
+	if _, err := w.Write(data); err != nil {
+		a.logger.Info("failed to write health status", zap.Error(err))
+	}
+}
+
+func (a *Agent) lastSuccess() time.Time {
+	nanos := a.lastSent.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func (a *Agent) markSent() {
+	a.lastSent.Store(time.Now().UnixNano())
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exposition builds the Prometheus text format out of metric IDs. Names and
+// label keys are sanitised and label values escaped, so that every family is
+// written once with a single type and every series once.
+type exposition struct {
+	families map[string]*family
+	skipped  []string
+}
+
+type family struct {
+	samples map[string]string
+	mType   string
+}
+
+func newExposition() *exposition {
+	return &exposition{families: make(map[string]*family)}
+}
+
+// add puts a series into the family of its sanitised name. A counter whose
+// name is taken by a gauge goes to name_total. A series that ends up the same
+// as one added before, e.g. a.b after a_b, is skipped.
+func (e *exposition) add(mType, id, value string) {
+	name, lbls, err := labels.Parse(id)
+	if err != nil {
+		name, lbls = id, labels.Labels{}
+	}
+	name = prometheusName(name)
+
+	f, ok := e.families[name]
+	if ok && f.mType != mType && mType == CounterType {
+		name += "_total"
+		f, ok = e.families[name]
+	}
+	if !ok {
+		f = &family{mType: mType, samples: make(map[string]string)}
+		e.families[name] = f
+	}
+
+	series := prometheusSeries(name, lbls)
+	if _, dup := f.samples[series]; dup || f.mType != mType {
+		e.skipped = append(e.skipped, id)
+		return
+	}
+	f.samples[series] = value
+}
+
+func (e *exposition) write(buf *bytes.Buffer) {
+	for _, name := range sortedKeys(e.families) {
+		f := e.families[name]
+		fmt.Fprintf(buf, "# TYPE %s %s\n", name, f.mType)
+		for _, series := range sortedKeys(f.samples) {
+			fmt.Fprintf(buf, "%s %s\n", series, f.samples[series])
+		}
+	}
+}
+
+// prometheusSeries formats a series the way the exposition format wants it:
+// label values only escape backslashes, double quotes and line feeds, unlike
+// Go quoting used in metric IDs.
+func prometheusSeries(name string, lbls labels.Labels) string {
+	if len(lbls) == 0 {
+		return name
+	}
+
+	seen := make(map[string]bool, len(lbls))
+	pairs := make([]string, 0, len(lbls))
+	for _, k := range sortedKeys(lbls) {
+		key := prometheusLabel(k)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		pairs = append(pairs, key+`="`+labelValueReplacer.Replace(strings.ToValidUTF8(lbls[k], "\uFFFD"))+`"`)
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusName replaces characters Prometheus does not allow in metric
+// names, e.g. HeapAlloc.max becomes HeapAlloc_max.
+func prometheusName(name string) string {
+	return sanitize(name, true)
+}
+
+// prometheusLabel does the same for label names, which may not contain colons.
+func prometheusLabel(name string) string {
+	return sanitize(name, false)
+}
+
+func sanitize(name string, colons bool) string {
+	if name == "" {
+		return "_"
+	}
+
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':' && colons:
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
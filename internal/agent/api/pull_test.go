@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestExposition(t *testing.T) {
+	type series struct {
+		mType string
+		id    string
+		value string
+	}
+	tests := []struct {
+		name        string
+		series      []series
+		want        string
+		wantSkipped []string
+	}{
+		{
+			name: "names are sanitised",
+			series: []series{
+				{GaugeType, "HeapAlloc.max", "1"},
+				{GaugeType, "9lives", "2"},
+			},
+			want: "# TYPE HeapAlloc_max gauge\nHeapAlloc_max 1\n# TYPE _9lives gauge\n_9lives 2\n",
+		},
+		{
+			name: "label values are escaped the Prometheus way",
+			series: []series{
+				{GaugeType, `Temp{room="a\"b\\c\nd",zone="\x01é"}`, "1"},
+			},
+			want: "# TYPE Temp gauge\nTemp{room=\"a\\\"b\\\\c\\nd\",zone=\"\x01é\"}" + " 1\n",
+		},
+		{
+			name: "label keys are sanitised",
+			series: []series{
+				{GaugeType, `Temp{k8s.pod="p1",my:key="v"}`, "1"},
+			},
+			want: "# TYPE Temp gauge\nTemp{k8s_pod=\"p1\",my_key=\"v\"} 1\n",
+		},
+		{
+			name: "gauge and counter with one name get a family each",
+			series: []series{
+				{GaugeType, "Requests", "5"},
+				{CounterType, "Requests", "7"},
+			},
+			want: "# TYPE Requests gauge\nRequests 5\n# TYPE Requests_total counter\nRequests_total 7\n",
+		},
+		{
+			name: "names that clash after sanitising are written once",
+			series: []series{
+				{GaugeType, "a.b", "1"},
+				{GaugeType, "a_b", "2"},
+				{GaugeType, `a.b{host="h"}`, "3"},
+			},
+			want:        "# TYPE a_b gauge\na_b 1\na_b{host=\"h\"} 3\n",
+			wantSkipped: []string{"a_b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newExposition()
+			for _, s := range tt.series {
+				e.add(s.mType, s.id, s.value)
+			}
+			var buf bytes.Buffer
+			e.write(&buf)
+
+			assert.Equal(t, buf.String(), tt.want)
+			assert.Equal(t, e.skipped, tt.wantSkipped)
+		})
+	}
+}
+
+func TestAgent_PrometheusHandler(t *testing.T) {
+	a := newTestAgent(t, config.AgentCfg{})
+	a.store.Gauge["Alloc"] = 1.5
+	a.store.Gauge[`Alloc.max{agent="a1"}`] = 2
+	a.store.Total["PollCount"] = 12
+
+	rr := httptest.NewRecorder()
+	a.PrometheusHandler(rr, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get(contentTypeStr), textPlainProm)
+	assert.Equal(t, rr.Body.String(), "# TYPE Alloc gauge\nAlloc 1.5\n"+
+		"# TYPE Alloc_max gauge\nAlloc_max{agent=\"a1\"} 2\n"+
+		"# TYPE PollCount counter\nPollCount 12\n")
+}
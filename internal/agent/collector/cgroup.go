@@ -0,0 +1,239 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/labels"
+)
+
+const (
+	cgroupName         = "cgroup"
+	cgroupErrorsMetric = "CgroupErrors"
+	defaultCgroupRoot  = "/sys/fs/cgroup"
+	selfCgroupFile     = "/proc/self/cgroup"
+)
+
+// cgroupCounters maps cpu.stat and summed io.stat keys to counter names.
+var cgroupCounters = map[string]map[string]string{
+	"cpu.stat": {
+		"usage_usec":     "CgroupCPUUsageUsec",
+		"user_usec":      "CgroupCPUUserUsec",
+		"system_usec":    "CgroupCPUSystemUsec",
+		"nr_periods":     "CgroupCPUPeriods",
+		"nr_throttled":   "CgroupCPUThrottledPeriods",
+		"throttled_usec": "CgroupCPUThrottledUsec",
+	},
+	"io.stat": {
+		"rbytes": "CgroupIOReadBytes",
+		"wbytes": "CgroupIOWriteBytes",
+		"rios":   "CgroupIOReads",
+		"wios":   "CgroupIOWrites",
+	},
+}
+
+// cgroupGauges maps single-value files to gauge names.
+var cgroupGauges = map[string]string{
+	"memory.current": "CgroupMemoryCurrent",
+	"memory.max":     "CgroupMemoryMax",
+	"pids.current":   "CgroupPids",
+}
+
+func init() {
+	Register(cgroupName, newCgroupCollector)
+}
+
+// cgroupCollector reports container-level usage from cgroup v2 files, which,
+// unlike /proc, account only for processes inside the container. Files of
+// controllers that are not enabled for a cgroup are skipped.
+type cgroupCollector struct {
+	counters *cumulative
+	root     string
+	paths    []string
+	interval time.Duration
+}
+
+func newCgroupCollector(cfg config.AgentCfg) (Collector, error) {
+	c := &cgroupCollector{
+		counters: newCumulative(false),
+		root:     cfg.Cgroup.Root,
+		interval: cfg.Cgroup.Interval.Duration,
+	}
+	if c.root == "" {
+		c.root = defaultCgroupRoot
+	}
+	if c.interval == 0 {
+		c.interval = pollInterval(cfg)
+	}
+
+	for _, p := range cfg.Cgroup.Paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(c.root, p)
+		}
+		c.paths = append(c.paths, filepath.Clean(p))
+	}
+
+	if len(c.paths) == 0 {
+		f, err := os.Open(selfCgroupFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the agent's cgroup: %w", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		self, err := parseSelfCgroup(f)
+		if err != nil {
+			return nil, err
+		}
+		c.paths = append(c.paths, filepath.Join(c.root, self))
+	}
+
+	return c, nil
+}
+
+// parseSelfCgroup finds the cgroup v2 entry, "0::/path", in /proc/self/cgroup.
+func parseSelfCgroup(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read the agent's cgroup: %w", err)
+	}
+	return "", errors.New("agent is not in a cgroup v2 hierarchy")
+}
+
+func (c *cgroupCollector) Name() string {
+	return cgroupName
+}
+
+func (c *cgroupCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *cgroupCollector) Collect(ctx context.Context) ([]Metric, error) {
+	var failed int64
+	metrics := make([]Metric, 0)
+	errs := make([]error, 0)
+
+	for _, path := range c.paths {
+		m, err := c.collectCgroup(path)
+		if err != nil {
+			failed++
+			errs = append(errs, err)
+			continue
+		}
+		metrics = append(metrics, m...)
+	}
+
+	metrics = append(metrics, Counter(cgroupErrorsMetric, failed))
+
+	return metrics, errors.Join(errs...)
+}
+
+func (c *cgroupCollector) collectCgroup(path string) ([]Metric, error) {
+	// cgroup.controllers есть в любом каталоге cgroup v2, но не v1.
+	if _, err := os.Stat(filepath.Join(path, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup v2 directory: %w", path, err)
+	}
+
+	name := path
+	if rel, err := filepath.Rel(c.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		name = filepath.Join("/", rel)
+	}
+	lbls := labels.Labels{"cgroup": name}
+
+	metrics := make([]Metric, 0)
+	for file, id := range cgroupGauges {
+		data, err := os.ReadFile(filepath.Join(path, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of %s: %w", file, path, err)
+		}
+
+		text := strings.TrimSpace(string(data))
+		// memory.max без ограничения содержит "max".
+		if text == "max" {
+			continue
+		}
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s of %s: %w", file, path, err)
+		}
+		metrics = append(metrics, Gauge(labels.Format(id, lbls), v))
+	}
+
+	for file, names := range cgroupCounters {
+		totals, err := readCgroupStat(filepath.Join(path, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for key, name := range names {
+			total, ok := totals[key]
+			if !ok {
+				continue
+			}
+			id := labels.Format(name, lbls)
+			metrics = append(metrics, Counter(id, c.counters.delta(path+" "+id, total)))
+		}
+	}
+
+	return metrics, nil
+}
+
+// readCgroupStat reads both "key value" lines (cpu.stat) and per-device
+// "8:0 key=value ..." lines (io.stat), summing values over devices.
+func readCgroupStat(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	totals := make(map[string]float64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && !strings.Contains(fields[1], "=") {
+			if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				totals[fields[0]] += v
+			}
+			continue
+		}
+
+		for _, f := range fields {
+			key, value, ok := strings.Cut(f, "=")
+			if !ok {
+				continue
+			}
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				totals[key] += v
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return totals, nil
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestCgroupCollector(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "system.slice", "app.service")
+	writeCgroup(t, dir, "usage_usec 1000\nnr_throttled 2\n", "8:0 rbytes=100 wbytes=10\n8:16 rbytes=50 wbytes=0\n")
+
+	cfg := config.AgentCfg{
+		PollInterval: 1,
+		Cgroup:       config.CgroupCfg{Root: root, Paths: []string{"system.slice/app.service", "missing"}},
+	}
+	c, err := newCgroupCollector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Collect(context.Background())
+	assert.NotEqual(t, err, nil)
+
+	writeCgroup(t, dir, "usage_usec 4000\nnr_throttled 5\n", "8:0 rbytes=300 wbytes=10\n8:16 rbytes=50 wbytes=0\n")
+	metrics, err := c.Collect(context.Background())
+	assert.NotEqual(t, err, nil)
+
+	got := make(map[string]Metric)
+	for _, m := range metrics {
+		got[m.ID] = m
+	}
+
+	lbls := `{cgroup="/system.slice/app.service"}`
+	assert.Equal(t, got["CgroupMemoryCurrent"+lbls], Gauge("CgroupMemoryCurrent"+lbls, 4096))
+	assert.Equal(t, got["CgroupPids"+lbls], Gauge("CgroupPids"+lbls, 3))
+	assert.Equal(t, got["CgroupCPUUsageUsec"+lbls], Counter("CgroupCPUUsageUsec"+lbls, 3000))
+	assert.Equal(t, got["CgroupCPUThrottledPeriods"+lbls], Counter("CgroupCPUThrottledPeriods"+lbls, 3))
+	assert.Equal(t, got["CgroupIOReadBytes"+lbls], Counter("CgroupIOReadBytes"+lbls, 200))
+	assert.Equal(t, got[cgroupErrorsMetric], Counter(cgroupErrorsMetric, 1))
+
+	_, ok := got["CgroupMemoryMax"+lbls]
+	assert.Equal(t, ok, false)
+}
+
+func TestParseSelfCgroup(t *testing.T) {
+	path, err := parseSelfCgroup(strings.NewReader("4:memory:/docker/abc\n0::/system.slice/app.service\n"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, path, "/system.slice/app.service")
+
+	_, err = parseSelfCgroup(strings.NewReader("4:memory:/docker/abc\n"))
+	assert.NotEqual(t, err, nil)
+}
+
+func writeCgroup(t *testing.T, dir, cpuStat, ioStat string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"cgroup.controllers": "cpu io memory pids\n",
+		"memory.current":     "4096\n",
+		"memory.max":         "max\n",
+		"pids.current":       "3\n",
+		"cpu.stat":           cpuStat,
+		"io.stat":            ioStat,
+	}
+	for file, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+)
+
+const (
+	GaugeType   string = "gauge"
+	CounterType string = "counter"
+)
+
+type Metric struct {
+	ID    string
+	MType string
+	Value float64
+	Delta int64
+}
+
+func Gauge(id string, value float64) Metric {
+	return Metric{ID: id, MType: GaugeType, Value: value}
+}
+
+func Counter(id string, delta int64) Metric {
+	return Metric{ID: id, MType: CounterType, Delta: delta}
+}
+
+// Collector is a source of metrics polled by the agent every Interval.
+// Collect may return partial results together with an error. Collectors that
+// hold files or other resources also implement io.Closer; the agent closes
+// them on shutdown.
+type Collector interface {
+	Name() string
+	Interval() time.Duration
+	Collect(ctx context.Context) ([]Metric, error)
+}
+
+type Factory func(cfg config.AgentCfg) (Collector, error)
+
+var (
+	registryLock = &sync.Mutex{}
+	registry     = make(map[string]Factory)
+)
+
+// Register makes a collector available under name, so it can be enabled in
+// the agent config. It is meant to be called from init of the collector file.
+func Register(name string, factory Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic("collector: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+func Registered() []string {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the collectors enabled in cfg in the order they are listed.
+func New(cfg config.AgentCfg) ([]Collector, error) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	collectors := make([]Collector, 0, len(cfg.Collectors))
+	seen := make(map[string]bool)
+
+	for _, name := range cfg.Collectors {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		factory, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+
+		c, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s collector: %w", name, err)
+		}
+		if c.Interval() <= 0 {
+			return nil, fmt.Errorf("%s collector has non-positive interval %s", name, c.Interval())
+		}
+		collectors = append(collectors, c)
+	}
+
+	return collectors, nil
+}
+
+func pollInterval(cfg config.AgentCfg) time.Duration {
+	return time.Duration(cfg.PollInterval) * time.Second
+}
@@ -0,0 +1,46 @@
+package collector
+
+import "math"
+
+// cumulative turns monotonically growing totals into the counter deltas the
+// agent reports. A total that goes down is treated as a reset of the source.
+type cumulative struct {
+	last map[string]float64
+	// countFirst reports the whole total on the first observation. It is
+	// only safe for sources that start together with the agent; for external
+	// ones the first observation is a baseline.
+	countFirst bool
+}
+
+func newCumulative(countFirst bool) *cumulative {
+	return &cumulative{
+		last:       make(map[string]float64),
+		countFirst: countFirst,
+	}
+}
+
+func (c *cumulative) delta(id string, total float64) int64 {
+	last, ok := c.last[id]
+	c.last[id] = total
+
+	switch {
+	case !ok && c.countFirst:
+		return int64(math.Floor(total))
+	case !ok:
+		return 0
+	case total < last:
+		return int64(math.Floor(total))
+	default:
+		return int64(math.Floor(total) - math.Floor(last))
+	}
+}
+
+// retain forgets totals of ids that are not in seen, e.g. of processes that
+// have exited.
+func (c *cumulative) retain(seen map[string]bool) {
+	for id := range c.last {
+		if !seen[id] {
+			delete(c.last, id)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+)
+
+const (
+	execName         = "exec"
+	execErrorsMetric = "ExecErrors"
+	execFormatText   = "text"
+	execFormatJSON   = "json"
+	execMaxOutput    = 1 << 20
+	// execWaitDelay bounds the wait for the output of children that outlive
+	// a command killed on timeout and keep its stdout open.
+	execWaitDelay = 500 * time.Millisecond
+)
+
+func init() {
+	Register(execName, newExecCollector)
+}
+
+type execCollector struct {
+	commands []config.ExecCommandCfg
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func newExecCollector(cfg config.AgentCfg) (Collector, error) {
+	c := &execCollector{
+		// Формат по умолчанию проставляется в копию, а не в конфигурацию.
+		commands: slices.Clone(cfg.Exec.Commands),
+		interval: cfg.Exec.Interval.Duration,
+		timeout:  cfg.Exec.Timeout.Duration,
+	}
+	if c.interval == 0 {
+		c.interval = pollInterval(cfg)
+	}
+	if c.timeout == 0 || c.timeout > c.interval {
+		c.timeout = c.interval
+	}
+
+	for i, cmd := range c.commands {
+		if len(cmd.Command) == 0 {
+			return nil, fmt.Errorf("exec command #%d has no command line", i)
+		}
+		switch cmd.Format {
+		case "":
+			c.commands[i].Format = execFormatText
+		case execFormatText, execFormatJSON:
+		default:
+			return nil, fmt.Errorf("exec command %q has unknown output format %q", cmd.Name, cmd.Format)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *execCollector) Name() string {
+	return execName
+}
+
+func (c *execCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *execCollector) Collect(ctx context.Context) ([]Metric, error) {
+	type result struct {
+		err     error
+		metrics []Metric
+	}
+
+	results := make([]result, len(c.commands))
+	wg := &sync.WaitGroup{}
+
+	for i, cmd := range c.commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metrics, err := c.run(ctx, cmd)
+			results[i] = result{metrics: metrics, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var failed int64
+	metrics := make([]Metric, 0)
+	errs := make([]error, 0)
+
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			errs = append(errs, r.err)
+			continue
+		}
+		metrics = append(metrics, r.metrics...)
+	}
+
+	// Always report the error counter so a healthy run shows up as zero.
+	metrics = append(metrics, Counter(execErrorsMetric, failed))
+
+	return metrics, errors.Join(errs...)
+}
+
+func (c *execCollector) run(ctx context.Context, cmd config.ExecCommandCfg) ([]Metric, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	name := cmd.Name
+	if name == "" {
+		name = cmd.Command[0]
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	//nolint:gosec // commands come from the agent config, not from the network
+	command := exec.CommandContext(ctx, cmd.Command[0], cmd.Command[1:]...)
+	command.Stdout = &limitedWriter{w: &stdout, n: execMaxOutput}
+	command.Stderr = &limitedWriter{w: &stderr, n: execMaxOutput}
+	command.WaitDelay = execWaitDelay
+
+	if err := command.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("exec command %q failed: %w: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("exec command %q failed: %w", name, err)
+	}
+
+	metrics, err := parseExecOutput(stdout.Bytes(), cmd.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse output of exec command %q: %w", name, err)
+	}
+	return metrics, nil
+}
+
+// parseExecOutput accepts either "name type value" lines or the JSON model the
+// server understands: a single object or an array of {"id", "type", "value"|"delta"}.
+func parseExecOutput(data []byte, format string) ([]Metric, error) {
+	if format == execFormatJSON {
+		return parseExecJSON(data)
+	}
+	return parseExecText(data)
+}
+
+func parseExecText(data []byte) ([]Metric, error) {
+	metrics := make([]Metric, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want \"name type value\", got %q", line, text)
+		}
+
+		m, err := parseMetric(fields[0], fields[1], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		metrics = append(metrics, m)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read output: %w", err)
+	}
+	return metrics, nil
+}
+
+func parseMetric(id, mType, value string) (Metric, error) {
+	switch mType {
+	case GaugeType:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Metric{}, fmt.Errorf("invalid gauge value %q: %w", value, err)
+		}
+		return Gauge(id, v), nil
+	case CounterType:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Metric{}, fmt.Errorf("invalid counter value %q: %w", value, err)
+		}
+		return Counter(id, v), nil
+	default:
+		return Metric{}, fmt.Errorf("wrong metric type %q - neither gauge nor counter", mType)
+	}
+}
+
+type execJSONMetric struct {
+	Value *float64 `json:"value,omitempty"`
+	Delta *int64   `json:"delta,omitempty"`
+	MType string   `json:"type"`
+	ID    string   `json:"id"`
+}
+
+func parseExecJSON(data []byte) ([]Metric, error) {
+	data = bytes.TrimSpace(data)
+
+	var items []execJSONMetric
+	if bytes.HasPrefix(data, []byte("{")) {
+		var item execJSONMetric
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("invalid JSON object: %w", err)
+		}
+		items = append(items, item)
+	} else if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid JSON array: %w", err)
+	}
+
+	metrics := make([]Metric, 0, len(items))
+	for _, item := range items {
+		if item.ID == "" {
+			return nil, errors.New("metric without id")
+		}
+
+		switch {
+		case item.MType == GaugeType && item.Value != nil:
+			metrics = append(metrics, Gauge(item.ID, *item.Value))
+		case item.MType == CounterType && item.Delta != nil:
+			metrics = append(metrics, Counter(item.ID, *item.Delta))
+		default:
+			return nil, fmt.Errorf("metric %q has wrong type %q or no value", item.ID, item.MType)
+		}
+	}
+	return metrics, nil
+}
+
+// limitedWriter silently drops output past n bytes so a chatty script can't
+// exhaust the agent's memory.
+type limitedWriter struct {
+	w *bytes.Buffer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if room := l.n - l.w.Len(); room > 0 {
+		if len(p) > room {
+			l.w.Write(p[:room])
+		} else {
+			l.w.Write(p)
+		}
+	}
+	return len(p), nil
+}
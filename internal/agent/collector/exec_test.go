@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestParseExecOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		format  string
+		want    []Metric
+		wantErr bool
+	}{
+		{
+			name:   "text lines with comments",
+			output: "# business metrics\nOrders counter 12\n\nCartValue gauge 99.5\n",
+			format: execFormatText,
+			want:   []Metric{Counter("Orders", 12), Gauge("CartValue", 99.5)},
+		},
+		{
+			name:    "text line with wrong type",
+			output:  "Orders histogram 12\n",
+			format:  execFormatText,
+			wantErr: true,
+		},
+		{
+			name:    "text counter with float value",
+			output:  "Orders counter 1.5\n",
+			format:  execFormatText,
+			wantErr: true,
+		},
+		{
+			name:   "json array",
+			output: `[{"id":"Orders","type":"counter","delta":3},{"id":"CartValue","type":"gauge","value":1.25}]`,
+			format: execFormatJSON,
+			want:   []Metric{Counter("Orders", 3), Gauge("CartValue", 1.25)},
+		},
+		{
+			name:   "json object",
+			output: `{"id":"CartValue","type":"gauge","value":7}`,
+			format: execFormatJSON,
+			want:   []Metric{Gauge("CartValue", 7)},
+		},
+		{
+			name:    "json gauge without value",
+			output:  `[{"id":"CartValue","type":"gauge","delta":7}]`,
+			format:  execFormatJSON,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExecOutput([]byte(tt.output), tt.format)
+			if tt.wantErr {
+				assert.NotEqual(t, err, nil)
+				return
+			}
+			assert.Equal(t, err, nil)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestExecCollector_Collect(t *testing.T) {
+	tests := []struct {
+		name       string
+		commands   []config.ExecCommandCfg
+		want       []Metric
+		wantErrors int64
+	}{
+		{
+			name: "command output is collected",
+			commands: []config.ExecCommandCfg{
+				{Name: "orders", Command: []string{"/bin/sh", "-c", "echo 'Orders counter 2'"}},
+			},
+			want: []Metric{Counter("Orders", 2)},
+		},
+		{
+			name: "failing command counts as an error",
+			commands: []config.ExecCommandCfg{
+				{Name: "orders", Command: []string{"/bin/sh", "-c", "echo 'Orders counter 2'"}},
+				{Name: "broken", Command: []string{"/bin/sh", "-c", "echo oops >&2; exit 3"}},
+			},
+			want:       []Metric{Counter("Orders", 2)},
+			wantErrors: 1,
+		},
+		{
+			name: "command past the timeout is killed",
+			commands: []config.ExecCommandCfg{
+				{Name: "slow", Command: []string{"/bin/sh", "-c", "sleep 5; echo 'Orders counter 2'"}},
+			},
+			want:       []Metric{},
+			wantErrors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newExecCollector(config.AgentCfg{Exec: config.ExecCfg{
+				Commands: tt.commands,
+				Interval: config.Duration{Duration: time.Second},
+				Timeout:  config.Duration{Duration: 200 * time.Millisecond},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Конфигурация вызывающего не меняется.
+			assert.Equal(t, tt.commands[0].Format, "")
+
+			started := time.Now()
+			got, err := c.Collect(context.Background())
+			assert.Equal(t, err != nil, tt.wantErrors > 0)
+			assert.Equal(t, time.Since(started) < 2*time.Second, true)
+			assert.Equal(t, got, append(tt.want, Counter(execErrorsMetric, tt.wantErrors)))
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+)
+
+const (
+	logTailName         = "logtail"
+	logTailErrorsMetric = "LogTailErrors"
+	logTailChunk        = 64 << 10
+	logTailMaxLine      = 1 << 20
+)
+
+func init() {
+	Register(logTailName, newLogTailCollector)
+}
+
+type logRule struct {
+	re     *regexp.Regexp
+	metric string
+	mType  string
+	group  int
+}
+
+// tailedFile follows one log file the way tail -F does: it keeps the file
+// open, so lines written just before a rotation are still read, and switches
+// to the new file once the old one is drained.
+type tailedFile struct {
+	file    *os.File
+	path    string
+	rules   []logRule
+	partial []byte
+	offset  int64
+	// skipping is set while dropping the rest of an overlong line.
+	skipping bool
+}
+
+type logTailCollector struct {
+	files    []*tailedFile
+	interval time.Duration
+}
+
+func newLogTailCollector(cfg config.AgentCfg) (Collector, error) {
+	c := &logTailCollector{
+		interval: cfg.LogTail.Interval.Duration,
+	}
+	if c.interval == 0 {
+		c.interval = pollInterval(cfg)
+	}
+
+	for _, fc := range cfg.LogTail.Files {
+		rules, err := newLogRules(fc)
+		if err != nil {
+			return nil, errors.Join(err, c.Close())
+		}
+
+		t := &tailedFile{path: fc.Path, rules: rules}
+		// Существующий файл читаем с конца, чтобы не учитывать старые строки
+		// при каждом перезапуске агента.
+		if err := t.open(true); err != nil {
+			return nil, errors.Join(err, c.Close())
+		}
+		c.files = append(c.files, t)
+	}
+
+	return c, nil
+}
+
+func newLogRules(fc config.LogFileCfg) ([]logRule, error) {
+	if fc.Path == "" {
+		return nil, errors.New("logtail file has no path")
+	}
+
+	rules := make([]logRule, 0, len(fc.Rules))
+	for _, rc := range fc.Rules {
+		if rc.Metric == "" {
+			return nil, fmt.Errorf("logtail rule for %s has no metric name", fc.Path)
+		}
+
+		re, err := regexp.Compile(rc.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile logtail rule %s: %w", rc.Metric, err)
+		}
+
+		r := logRule{re: re, metric: rc.Metric, mType: rc.Type, group: -1}
+		if rc.Group != "" {
+			r.group = re.SubexpIndex(rc.Group)
+			if r.group < 0 {
+				return nil, fmt.Errorf("logtail rule %s has no group %q in %q", rc.Metric, rc.Group, rc.Regexp)
+			}
+		}
+
+		switch r.mType {
+		case "":
+			r.mType = CounterType
+		case CounterType:
+		case GaugeType:
+			if r.group < 0 {
+				return nil, fmt.Errorf("logtail gauge %s needs a group to take the value from", rc.Metric)
+			}
+		default:
+			return nil, fmt.Errorf("logtail rule %s has unknown metric type %q", rc.Metric, rc.Type)
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+func (c *logTailCollector) Name() string {
+	return logTailName
+}
+
+func (c *logTailCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *logTailCollector) Collect(ctx context.Context) ([]Metric, error) {
+	var failed int64
+	metrics := make([]Metric, 0)
+	errs := make([]error, 0)
+
+	for _, t := range c.files {
+		counters := make(map[string]int64)
+		gauges := make(map[string]float64)
+		for _, r := range t.rules {
+			if r.mType == CounterType {
+				counters[r.metric] = 0
+			}
+		}
+
+		err := t.poll(ctx, func(line []byte) {
+			t.match(line, counters, gauges)
+		})
+		if err != nil {
+			failed++
+			errs = append(errs, err)
+		}
+
+		for id, delta := range counters {
+			metrics = append(metrics, Counter(id, delta))
+		}
+		for id, value := range gauges {
+			metrics = append(metrics, Gauge(id, value))
+		}
+	}
+
+	metrics = append(metrics, Counter(logTailErrorsMetric, failed))
+
+	return metrics, errors.Join(errs...)
+}
+
+// Close releases the followed files.
+func (c *logTailCollector) Close() error {
+	errs := make([]error, 0, len(c.files))
+	for _, t := range c.files {
+		errs = append(errs, t.close())
+	}
+	return errors.Join(errs...)
+}
+
+func (t *tailedFile) match(line []byte, counters map[string]int64, gauges map[string]float64) {
+	for _, r := range t.rules {
+		m := r.re.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		if r.group < 0 {
+			counters[r.metric]++
+			continue
+		}
+
+		value, err := strconv.ParseFloat(string(m[r.group]), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		switch r.mType {
+		case CounterType:
+			counters[r.metric] += int64(math.Round(value))
+		case GaugeType:
+			gauges[r.metric] = value
+		}
+	}
+}
+
+// open opens the file at path, at its end if atEnd is set. A file that does
+// not exist yet is not an error: it is picked up from the start once created.
+func (t *tailedFile) open(atEnd bool) error {
+	if err := t.close(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	var offset int64
+	if atEnd {
+		info, err := f.Stat()
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to stat log file: %w", err), f.Close())
+		}
+		offset = info.Size()
+	}
+
+	t.file, t.offset, t.partial, t.skipping = f, offset, nil, false
+	return nil
+}
+
+func (t *tailedFile) close() error {
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close log file %s: %w", t.path, err)
+	}
+	return nil
+}
+
+// poll passes every complete line written since the last call to emit.
+func (t *tailedFile) poll(ctx context.Context, emit func([]byte)) error {
+	if t.file == nil {
+		if err := t.open(false); err != nil || t.file == nil {
+			return err
+		}
+	}
+
+	info, err := t.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file %s: %w", t.path, err)
+	}
+	if info.Size() < t.offset {
+		t.offset, t.partial, t.skipping = 0, nil, false
+	}
+
+	if err := t.read(ctx, emit); err != nil {
+		return err
+	}
+
+	current, err := os.Stat(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Файл переименован, а новый ещё не создан: продолжаем читать старый.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat log file %s: %w", t.path, err)
+	}
+	if os.SameFile(info, current) {
+		return nil
+	}
+
+	if len(t.partial) > 0 {
+		emit(t.partial)
+	}
+
+	if err := t.open(false); err != nil || t.file == nil {
+		return err
+	}
+	return t.read(ctx, emit)
+}
+
+func (t *tailedFile) read(ctx context.Context, emit func([]byte)) error {
+	buf := make([]byte, logTailChunk)
+	for ctx.Err() == nil {
+		n, err := t.file.ReadAt(buf, t.offset)
+		if n > 0 {
+			t.offset += int64(n)
+			t.consume(buf[:n], emit)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read log file %s: %w", t.path, err)
+		}
+	}
+	return nil
+}
+
+// consume splits data into lines, keeping an unfinished last line until the
+// rest of it is written. Lines longer than logTailMaxLine are skipped.
+func (t *tailedFile) consume(data []byte, emit func([]byte)) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			if t.skipping {
+				return
+			}
+			if len(t.partial)+len(data) > logTailMaxLine {
+				t.partial, t.skipping = t.partial[:0], true
+				return
+			}
+			t.partial = append(t.partial, data...)
+			return
+		}
+
+		if t.skipping {
+			t.skipping = false
+			data = data[i+1:]
+			continue
+		}
+
+		line := data[:i]
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+		}
+		emit(bytes.TrimSuffix(line, []byte{'\r'}))
+		t.partial = t.partial[:0]
+		data = data[i+1:]
+	}
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestLogTailCollector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "level=error msg=old\n")
+
+	cfg := config.AgentCfg{
+		PollInterval: 1,
+		LogTail: config.LogTailCfg{
+			Files: []config.LogFileCfg{{
+				Path: path,
+				Rules: []config.LogRuleCfg{
+					{Metric: "AppErrors", Regexp: `level=error`},
+					{Metric: "AppDuration", Type: GaugeType, Regexp: `duration_ms=(?P<ms>[0-9.]+)`, Group: "ms"},
+					{Metric: "AppBytes", Regexp: `bytes=(?P<n>\d+)`, Group: "n"},
+				},
+			}},
+		},
+	}
+	c, err := newLogTailCollector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		write func()
+		want  map[string]Metric
+		name  string
+	}{
+		{
+			name:  "lines written before start are skipped",
+			write: func() {},
+			want: map[string]Metric{
+				"AppErrors": Counter("AppErrors", 0),
+				"AppBytes":  Counter("AppBytes", 0),
+			},
+		},
+		{
+			name: "new lines are matched and a partial line waits",
+			write: func() {
+				appendLog(t, path, "level=error duration_ms=12.5 bytes=100\nlevel=info duration_ms=7 bytes=")
+			},
+			want: map[string]Metric{
+				"AppErrors":   Counter("AppErrors", 1),
+				"AppDuration": Gauge("AppDuration", 12.5),
+				"AppBytes":    Counter("AppBytes", 100),
+			},
+		},
+		{
+			name: "rotation drains the old file before the new one",
+			write: func() {
+				appendLog(t, path, "50\nlevel=error\n")
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				appendLog(t, path+".1", "level=error\n")
+				appendLog(t, path, "level=error bytes=5\n")
+			},
+			want: map[string]Metric{
+				"AppErrors":   Counter("AppErrors", 3),
+				"AppDuration": Gauge("AppDuration", 7),
+				"AppBytes":    Counter("AppBytes", 55),
+			},
+		},
+		{
+			name: "truncated file is read from the start",
+			write: func() {
+				if err := os.Truncate(path, 0); err != nil {
+					t.Fatal(err)
+				}
+				appendLog(t, path, "level=error\n")
+			},
+			want: map[string]Metric{
+				"AppErrors": Counter("AppErrors", 1),
+				"AppBytes":  Counter("AppBytes", 0),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.write()
+
+			metrics, err := c.Collect(context.Background())
+			assert.Equal(t, err, nil)
+
+			got := make(map[string]Metric)
+			for _, m := range metrics {
+				if m.ID != logTailErrorsMetric {
+					got[m.ID] = m
+				}
+			}
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestLogTailCollector_Close(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "level=error\n")
+
+	cfg := config.AgentCfg{
+		PollInterval: 1,
+		LogTail: config.LogTailCfg{
+			Files: []config.LogFileCfg{{
+				Path:  path,
+				Rules: []config.LogRuleCfg{{Metric: "AppErrors", Regexp: `level=error`}},
+			}},
+		},
+	}
+	lc, err := newLogTailCollector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := lc.(*logTailCollector)
+	tailed := c.files[0]
+
+	// После ротации прежний дескриптор закрыт.
+	rotated := tailed.file
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, path, "level=error\n")
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, errors.Is(rotated.Close(), os.ErrClosed), true)
+
+	current := tailed.file
+	assert.Equal(t, c.Close(), nil)
+	assert.Equal(t, tailed.file == nil, true)
+	assert.Equal(t, errors.Is(current.Close(), os.ErrClosed), true)
+}
+
+func appendLog(t *testing.T, path, data string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
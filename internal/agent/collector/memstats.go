@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+)
+
+const memStatsName = "memstats"
+
+func init() {
+	Register(memStatsName, newMemStatsCollector)
+}
+
+type memStatsCollector struct {
+	interval time.Duration
+}
+
+func newMemStatsCollector(cfg config.AgentCfg) (Collector, error) {
+	return &memStatsCollector{interval: pollInterval(cfg)}, nil
+}
+
+func (c *memStatsCollector) Name() string {
+	return memStatsName
+}
+
+func (c *memStatsCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *memStatsCollector) Collect(ctx context.Context) ([]Metric, error) {
+	m := new(runtime.MemStats)
+	runtime.ReadMemStats(m)
+
+	return []Metric{
+		Gauge("Alloc", float64(m.Alloc)),
+		Gauge("BuckHashSys", float64(m.BuckHashSys)),
+		Gauge("Frees", float64(m.Frees)),
+		Gauge("GCCPUFraction", m.GCCPUFraction),
+		Gauge("GCSys", float64(m.GCSys)),
+		Gauge("HeapAlloc", float64(m.HeapAlloc)),
+		Gauge("HeapIdle", float64(m.HeapIdle)),
+		Gauge("HeapInuse", float64(m.HeapInuse)),
+		Gauge("HeapObjects", float64(m.HeapObjects)),
+		Gauge("HeapReleased", float64(m.HeapReleased)),
+		Gauge("HeapSys", float64(m.HeapSys)),
+		Gauge("LastGC", float64(m.LastGC)),
+		Gauge("Lookups", float64(m.Lookups)),
+		Gauge("MCacheInuse", float64(m.MCacheInuse)),
+		Gauge("MCacheSys", float64(m.MCacheSys)),
+		Gauge("MSpanInuse", float64(m.MSpanInuse)),
+		Gauge("MSpanSys", float64(m.MSpanSys)),
+		Gauge("Mallocs", float64(m.Mallocs)),
+		Gauge("NextGC", float64(m.NextGC)),
+		Gauge("NumForcedGC", float64(m.NumForcedGC)),
+		Gauge("NumGC", float64(m.NumGC)),
+		Gauge("OtherSys", float64(m.OtherSys)),
+		Gauge("PauseTotalNs", float64(m.PauseTotalNs)),
+		Gauge("StackInuse", float64(m.StackInuse)),
+		Gauge("StackSys", float64(m.StackSys)),
+		Gauge("Sys", float64(m.Sys)),
+		Gauge("TotalAlloc", float64(m.TotalAlloc)),
+		Gauge("RandomValue", rand.Float64()),
+		Counter("PollCount", 1),
+	}, nil
+}
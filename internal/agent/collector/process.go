@@ -0,0 +1,296 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/labels"
+)
+
+const (
+	processName         = "process"
+	processErrorsMetric = "ProcessErrors"
+	processCountMetric  = "ProcessCount"
+	defaultProcRoot     = "/proc"
+	// clockTicks is USER_HZ, the unit of CPU times in /proc/<pid>/stat. It is
+	// 100 on every architecture Linux supports.
+	clockTicks = 100
+)
+
+func init() {
+	Register(processName, newProcessCollector)
+}
+
+type procStat struct {
+	name  string
+	start uint64
+	utime uint64
+	stime uint64
+	pid   int
+}
+
+// processCollector reports CPU time, memory, threads, open files and disk
+// I/O of selected processes, labelled by process name and PID.
+type processCollector struct {
+	counters *cumulative
+	root     string
+	patterns []*regexp.Regexp
+	pids     []int
+	interval time.Duration
+}
+
+func newProcessCollector(cfg config.AgentCfg) (Collector, error) {
+	c := &processCollector{
+		counters: newCumulative(false),
+		root:     cfg.Process.ProcRoot,
+		pids:     cfg.Process.PIDs,
+		interval: cfg.Process.Interval.Duration,
+	}
+	if c.root == "" {
+		c.root = defaultProcRoot
+	}
+	if c.interval == 0 {
+		c.interval = pollInterval(cfg)
+	}
+
+	for _, name := range cfg.Process.Names {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile process name pattern: %w", err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+
+	return c, nil
+}
+
+func (c *processCollector) Name() string {
+	return processName
+}
+
+func (c *processCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *processCollector) Collect(ctx context.Context) ([]Metric, error) {
+	var failed int64
+	metrics := make([]Metric, 0)
+	errs := make([]error, 0)
+	seen := make(map[string]bool)
+	reported := make(map[int]bool)
+
+	for _, pid := range c.pids {
+		st, err := c.readStat(pid)
+		if err != nil {
+			failed++
+			errs = append(errs, err)
+			continue
+		}
+		reported[pid] = true
+		metrics = append(metrics, c.processMetrics(st, seen)...)
+	}
+
+	if len(c.patterns) > 0 {
+		pids, err := c.listPIDs()
+		if err != nil {
+			failed++
+			errs = append(errs, err)
+		}
+
+		counts := make([]int, len(c.patterns))
+		for _, pid := range pids {
+			if ctx.Err() != nil {
+				break
+			}
+
+			// Процесс мог завершиться во время обхода — это не ошибка.
+			st, err := c.readStat(pid)
+			if err != nil {
+				continue
+			}
+
+			cmdline := c.readCmdline(pid)
+			matched := false
+			for i, re := range c.patterns {
+				if re.MatchString(st.name) || re.MatchString(cmdline) {
+					counts[i]++
+					matched = true
+				}
+			}
+			if matched && !reported[pid] {
+				reported[pid] = true
+				metrics = append(metrics, c.processMetrics(st, seen)...)
+			}
+		}
+
+		for i, re := range c.patterns {
+			id := labels.Format(processCountMetric, labels.Labels{"pattern": re.String()})
+			metrics = append(metrics, Gauge(id, float64(counts[i])))
+		}
+	}
+
+	c.counters.retain(seen)
+	metrics = append(metrics, Counter(processErrorsMetric, failed))
+
+	return metrics, errors.Join(errs...)
+}
+
+// processMetrics reads the rest of the process files. status, io and fd of
+// other users' processes are only readable by root, so whatever cannot be
+// read is left out.
+func (c *processCollector) processMetrics(st procStat, seen map[string]bool) []Metric {
+	lbls := labels.Labels{"name": st.name, "pid": strconv.Itoa(st.pid)}
+	// Ключ включает время старта, чтобы переиспользованный PID не давал
+	// ложного сброса счётчиков.
+	key := fmt.Sprintf("%d/%d ", st.pid, st.start)
+
+	counter := func(name string, total uint64) Metric {
+		id := labels.Format(name, lbls)
+		seen[key+id] = true
+		return Counter(id, c.counters.delta(key+id, float64(total)))
+	}
+
+	metrics := []Metric{
+		counter("ProcessCPUUserMs", st.utime*1000/clockTicks),
+		counter("ProcessCPUSystemMs", st.stime*1000/clockTicks),
+	}
+
+	if status, err := c.readKeyValues(st.pid, "status"); err == nil {
+		if rss, ok := status["VmRSS"]; ok {
+			metrics = append(metrics, Gauge(labels.Format("ProcessRSS", lbls), float64(rss*1024)))
+		}
+		if threads, ok := status["Threads"]; ok {
+			metrics = append(metrics, Gauge(labels.Format("ProcessThreads", lbls), float64(threads)))
+		}
+	}
+
+	if fds, err := os.ReadDir(c.path(st.pid, "fd")); err == nil {
+		metrics = append(metrics, Gauge(labels.Format("ProcessOpenFDs", lbls), float64(len(fds))))
+	}
+
+	if io, err := c.readKeyValues(st.pid, "io"); err == nil {
+		metrics = append(metrics,
+			counter("ProcessReadBytes", io["read_bytes"]),
+			counter("ProcessWriteBytes", io["write_bytes"]),
+		)
+	}
+
+	return metrics
+}
+
+func (c *processCollector) path(pid int, name string) string {
+	return filepath.Join(c.root, strconv.Itoa(pid), name)
+}
+
+func (c *processCollector) listPIDs() ([]int, error) {
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	pids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// readStat parses /proc/<pid>/stat. The command name is in parentheses and
+// may itself contain spaces and parentheses, so fields are counted from the
+// last closing one.
+func (c *processCollector) readStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(c.path(pid, "stat"))
+	if err != nil {
+		return procStat{}, fmt.Errorf("failed to read stat of process %d: %w", pid, err)
+	}
+
+	open := bytes.IndexByte(data, '(')
+	closing := bytes.LastIndexByte(data, ')')
+	if open < 0 || closing < open {
+		return procStat{}, fmt.Errorf("malformed stat of process %d", pid)
+	}
+
+	// После имени идут поля начиная с третьего (state), см. proc(5).
+	fields := strings.Fields(string(data[closing+1:]))
+	const (
+		utimeField = 14 - 3
+		stimeField = 15 - 3
+		startField = 22 - 3
+	)
+	if len(fields) <= startField {
+		return procStat{}, fmt.Errorf("malformed stat of process %d: %d fields", pid, len(fields))
+	}
+
+	st := procStat{pid: pid, name: string(data[open+1 : closing])}
+	for _, f := range []struct {
+		dst   *uint64
+		index int
+	}{
+		{dst: &st.utime, index: utimeField},
+		{dst: &st.stime, index: stimeField},
+		{dst: &st.start, index: startField},
+	} {
+		*f.dst, err = strconv.ParseUint(fields[f.index], 10, 64)
+		if err != nil {
+			return procStat{}, fmt.Errorf("malformed stat of process %d: %w", pid, err)
+		}
+	}
+
+	return st, nil
+}
+
+func (c *processCollector) readCmdline(pid int) string {
+	data, err := os.ReadFile(c.path(pid, "cmdline"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bytes.ReplaceAll(data, []byte{0}, []byte{' '})))
+}
+
+// readKeyValues reads files of "Key: value [kB]" lines such as status and
+// io, keeping the numeric values.
+func (c *processCollector) readKeyValues(pid int, name string) (map[string]uint64, error) {
+	f, err := os.Open(c.path(pid, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s of process %d: %w", name, pid, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[key] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s of process %d: %w", name, pid, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestProcessCollector(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, 10, "nginx: worker", 150, 50, 2048, 1000, 3)
+	writeProc(t, root, 20, "postgres", 10, 10, 1024, 0, 1)
+
+	cfg := config.AgentCfg{
+		PollInterval: 1,
+		Process: config.ProcessCfg{
+			PIDs:     []int{20, 30},
+			Names:    []string{"^nginx"},
+			ProcRoot: root,
+		},
+	}
+	c, err := newProcessCollector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Collect(context.Background())
+	assert.NotEqual(t, err, nil)
+
+	writeProc(t, root, 10, "nginx: worker", 250, 60, 4096, 1500, 3)
+	metrics, err := c.Collect(context.Background())
+	assert.NotEqual(t, err, nil)
+
+	got := make(map[string]Metric)
+	for _, m := range metrics {
+		got[m.ID] = m
+	}
+
+	nginx := `{name="nginx: worker",pid="10"}`
+	assert.Equal(t, got["ProcessCPUUserMs"+nginx], Counter("ProcessCPUUserMs"+nginx, 1000))
+	assert.Equal(t, got["ProcessCPUSystemMs"+nginx], Counter("ProcessCPUSystemMs"+nginx, 100))
+	assert.Equal(t, got["ProcessRSS"+nginx], Gauge("ProcessRSS"+nginx, 4096*1024))
+	assert.Equal(t, got["ProcessThreads"+nginx], Gauge("ProcessThreads"+nginx, 3))
+	assert.Equal(t, got["ProcessOpenFDs"+nginx], Gauge("ProcessOpenFDs"+nginx, 2))
+	assert.Equal(t, got["ProcessReadBytes"+nginx], Counter("ProcessReadBytes"+nginx, 500))
+
+	postgres := `{name="postgres",pid="20"}`
+	assert.Equal(t, got["ProcessCPUUserMs"+postgres], Counter("ProcessCPUUserMs"+postgres, 0))
+
+	assert.Equal(t, got[`ProcessCount{pattern="^nginx"}`], Gauge(`ProcessCount{pattern="^nginx"}`, 1))
+	assert.Equal(t, got[processErrorsMetric], Counter(processErrorsMetric, 1))
+}
+
+func writeProc(t *testing.T, root string, pid int, name string, utime, stime, rssKB, readBytes, threads int) {
+	t.Helper()
+
+	dir := filepath.Join(root, fmt.Sprint(pid))
+	if err := os.MkdirAll(filepath.Join(dir, "fd"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"stat": fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 4194624 100 0 0 0 %d %d 0 0 20 0 %d 0 12345 1000 250",
+			pid, name, pid, pid, utime, stime, threads),
+		"status":  fmt.Sprintf("Name:\t%s\nVmRSS:\t%d kB\nThreads:\t%d\n", name, rssKB, threads),
+		"io":      fmt.Sprintf("rchar: 1\nwchar: 2\nread_bytes: %d\nwrite_bytes: 0\n", readBytes),
+		"cmdline": name + "\x00",
+		"fd/0":    "",
+		"fd/1":    "",
+	}
+	for file, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
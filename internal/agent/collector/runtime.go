@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+)
+
+const runtimeName = "runtime"
+
+var runtimeQuantiles = []struct {
+	stat string
+	q    float64
+}{
+	{stat: "p50", q: 0.5},
+	{stat: "p90", q: 0.9},
+	{stat: "p99", q: 0.99},
+}
+
+func init() {
+	Register(runtimeName, newRuntimeCollector)
+}
+
+// runtimeCollector exports every sample runtime/metrics supports. Cumulative
+// values become counters (seconds are reported in whole nanoseconds), the
+// rest become gauges, and distributions are summarised per poll window as
+// name.count plus name.p50/p90/p99/max gauges.
+type runtimeCollector struct {
+	counters   *cumulative
+	histograms map[string][]uint64
+	descs      map[string]metrics.Description
+	samples    []metrics.Sample
+	interval   time.Duration
+}
+
+func newRuntimeCollector(cfg config.AgentCfg) (Collector, error) {
+	c := &runtimeCollector{
+		counters:   newCumulative(true),
+		histograms: make(map[string][]uint64),
+		descs:      make(map[string]metrics.Description),
+		interval:   pollInterval(cfg),
+	}
+
+	for _, d := range metrics.All() {
+		if d.Kind == metrics.KindBad {
+			continue
+		}
+		c.descs[d.Name] = d
+		c.samples = append(c.samples, metrics.Sample{Name: d.Name})
+	}
+
+	return c, nil
+}
+
+func (c *runtimeCollector) Name() string {
+	return runtimeName
+}
+
+func (c *runtimeCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *runtimeCollector) Collect(ctx context.Context) ([]Metric, error) {
+	metrics.Read(c.samples)
+
+	result := make([]Metric, 0, len(c.samples))
+	for _, s := range c.samples {
+		d := c.descs[s.Name]
+		name := runtimeMetricName(s.Name)
+
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			v := float64(s.Value.Uint64())
+			if d.Cumulative {
+				result = append(result, Counter(name, c.counters.delta(name, v)))
+			} else {
+				result = append(result, Gauge(name, v))
+			}
+		case metrics.KindFloat64:
+			v := s.Value.Float64()
+			if d.Cumulative {
+				if nanosName, ok := nanosecondsName(s.Name); ok {
+					name, v = nanosName, v*float64(time.Second)
+				}
+				result = append(result, Counter(name, c.counters.delta(name, v)))
+			} else {
+				result = append(result, Gauge(name, v))
+			}
+		case metrics.KindFloat64Histogram:
+			result = append(result, c.summarise(name, s.Value.Float64Histogram())...)
+		case metrics.KindBad:
+		}
+	}
+
+	return result, nil
+}
+
+func (c *runtimeCollector) summarise(name string, h *metrics.Float64Histogram) []Metric {
+	prev := c.histograms[name]
+	window := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, count := range h.Counts {
+		window[i] = count
+		if len(prev) == len(h.Counts) && count >= prev[i] {
+			window[i] = count - prev[i]
+		}
+		total += window[i]
+	}
+	c.histograms[name] = append(prev[:0], h.Counts...)
+
+	result := []Metric{Counter(name+".count", int64(total))}
+	if total == 0 {
+		return result
+	}
+
+	for _, q := range runtimeQuantiles {
+		if v, ok := histogramQuantile(h.Buckets, window, total, q.q); ok {
+			result = append(result, Gauge(name+"."+q.stat, v))
+		}
+	}
+	if v, ok := histogramQuantile(h.Buckets, window, total, 1); ok {
+		result = append(result, Gauge(name+".max", v))
+	}
+	return result
+}
+
+// histogramQuantile returns the upper bound of the bucket holding the
+// quantile, falling back to the lower bound for the open-ended last bucket.
+// A bucket open at both ends, such as a single (-Inf, +Inf) one, has no
+// finite bound to report.
+func histogramQuantile(buckets []float64, counts []uint64, total uint64, q float64) (float64, bool) {
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, count := range counts {
+		seen += count
+		if seen >= rank && count > 0 {
+			for _, bound := range []float64{buckets[i+1], buckets[i]} {
+				if !math.IsInf(bound, 0) {
+					return bound, true
+				}
+			}
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// runtimeMetricName maps /gc/pauses:seconds to go_gc_pauses_seconds.
+func runtimeMetricName(name string) string {
+	var b strings.Builder
+	b.WriteString("go")
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func nanosecondsName(name string) (string, bool) {
+	path, unit, ok := strings.Cut(name, ":")
+	if !ok {
+		return "", false
+	}
+
+	switch unit {
+	case "seconds":
+		return runtimeMetricName(path + ":nanoseconds"), true
+	case "cpu-seconds":
+		return runtimeMetricName(path + ":cpu-nanoseconds"), true
+	default:
+		return "", false
+	}
+}
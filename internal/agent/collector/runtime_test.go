@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		name    string
+		buckets []float64
+		counts  []uint64
+		q       float64
+		want    float64
+		wantOK  bool
+	}{
+		{
+			name:    "upper bound of the bucket holding the quantile",
+			buckets: []float64{0, 1, 2, 4},
+			counts:  []uint64{5, 4, 1},
+			q:       0.5,
+			want:    1,
+			wantOK:  true,
+		},
+		{
+			name:    "max lands in the last finite bucket",
+			buckets: []float64{0, 1, 2, 4},
+			counts:  []uint64{5, 4, 1},
+			q:       1,
+			want:    4,
+			wantOK:  true,
+		},
+		{
+			name:    "open-ended last bucket falls back to its lower bound",
+			buckets: []float64{0, 1, inf},
+			counts:  []uint64{1, 3},
+			q:       0.9,
+			want:    1,
+			wantOK:  true,
+		},
+		{
+			name:    "open-ended first bucket reports its upper bound",
+			buckets: []float64{-inf, 1, 2},
+			counts:  []uint64{3, 1},
+			q:       0.5,
+			want:    1,
+			wantOK:  true,
+		},
+		{
+			name:    "single bucket open at both ends",
+			buckets: []float64{-inf, inf},
+			counts:  []uint64{7},
+			q:       0.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var total uint64
+			for _, c := range tt.counts {
+				total += c
+			}
+			got, ok := histogramQuantile(tt.buckets, tt.counts, total, tt.q)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestRuntimeCollector_Summarise(t *testing.T) {
+	c := &runtimeCollector{histograms: make(map[string][]uint64)}
+	h := &metrics.Float64Histogram{Buckets: []float64{0, 1, 2, math.Inf(1)}, Counts: []uint64{2, 0, 0}}
+
+	assert.Equal(t, c.summarise("go_lat", h), []Metric{
+		Counter("go_lat.count", 2),
+		Gauge("go_lat.p50", 1), Gauge("go_lat.p90", 1), Gauge("go_lat.p99", 1), Gauge("go_lat.max", 1),
+	})
+
+	// Во втором окне учитываются только новые наблюдения.
+	h = &metrics.Float64Histogram{Buckets: h.Buckets, Counts: []uint64{2, 0, 3}}
+	assert.Equal(t, c.summarise("go_lat", h), []Metric{
+		Counter("go_lat.count", 3),
+		Gauge("go_lat.p50", 2), Gauge("go_lat.p90", 2), Gauge("go_lat.p99", 2), Gauge("go_lat.max", 2),
+	})
+
+	assert.Equal(t, c.summarise("go_lat", h), []Metric{Counter("go_lat.count", 0)})
+
+	open := &metrics.Float64Histogram{Buckets: []float64{math.Inf(-1), math.Inf(1)}, Counts: []uint64{4}}
+	assert.Equal(t, c.summarise("go_open", open), []Metric{Counter("go_open.count", 4)})
+}
+
+func TestRuntimeCollector_Collect(t *testing.T) {
+	c, err := newRuntimeCollector(config.AgentCfg{PollInterval: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	got, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byID := make(map[string]Metric, len(got))
+	for _, m := range got {
+		assert.Equal(t, strings.HasPrefix(m.ID, "go_"), true)
+		assert.Equal(t, math.IsInf(m.Value, 0) || math.IsNaN(m.Value), false)
+		if m.MType == CounterType {
+			assert.Equal(t, m.Delta >= 0, true)
+		}
+		byID[m.ID] = m
+	}
+
+	gcCycles, ok := byID["go_gc_cycles_total_gc_cycles"]
+	assert.Equal(t, ok, true)
+	assert.Equal(t, gcCycles.MType, CounterType)
+	assert.Equal(t, gcCycles.Delta >= 1, true)
+	assert.Equal(t, byID["go_sched_goroutines_goroutines"].MType, GaugeType)
+}
@@ -0,0 +1,256 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/labels"
+)
+
+const (
+	scrapeName         = "scrape"
+	scrapeErrorsMetric = "ScrapeErrors"
+	scrapeMaxBody      = 10 << 20
+)
+
+const (
+	promCounter = "counter"
+	promGauge   = "gauge"
+	promUntyped = "untyped"
+)
+
+func init() {
+	Register(scrapeName, newScrapeCollector)
+}
+
+type scrapeCollector struct {
+	client   *http.Client
+	counters *cumulative
+	targets  []config.ScrapeTargetCfg
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func newScrapeCollector(cfg config.AgentCfg) (Collector, error) {
+	c := &scrapeCollector{
+		client:   &http.Client{},
+		counters: newCumulative(false),
+		targets:  cfg.Scrape.Targets,
+		interval: cfg.Scrape.Interval.Duration,
+		timeout:  cfg.Scrape.Timeout.Duration,
+	}
+	if c.interval == 0 {
+		c.interval = pollInterval(cfg)
+	}
+	if c.timeout == 0 || c.timeout > c.interval {
+		c.timeout = c.interval
+	}
+
+	for _, t := range c.targets {
+		if !strings.HasPrefix(t.URL, "http://") && !strings.HasPrefix(t.URL, "https://") {
+			return nil, fmt.Errorf("scrape target %q must be an http(s) URL", t.URL)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *scrapeCollector) Name() string {
+	return scrapeName
+}
+
+func (c *scrapeCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *scrapeCollector) Collect(ctx context.Context) ([]Metric, error) {
+	var failed int64
+	metrics := make([]Metric, 0)
+	errs := make([]error, 0)
+
+	for _, t := range c.targets {
+		samples, err := c.scrape(ctx, t.URL)
+		if err != nil {
+			failed++
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, s := range samples {
+			id := labels.Format(t.Prefix+s.name, s.labels)
+			switch s.mType {
+			case promCounter:
+				metrics = append(metrics, Counter(id, c.counters.delta(t.URL+" "+id, s.value)))
+			default:
+				metrics = append(metrics, Gauge(id, s.value))
+			}
+		}
+	}
+
+	metrics = append(metrics, Counter(scrapeErrorsMetric, failed))
+
+	return metrics, errors.Join(errs...)
+}
+
+func (c *scrapeCollector) scrape(ctx context.Context, url string) ([]promSample, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a scrape request: %w", err)
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape %s: %w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to scrape %s: HTTP status %d", url, resp.StatusCode)
+	}
+
+	samples, err := parsePrometheusText(io.LimitReader(resp.Body, scrapeMaxBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics from %s: %w", url, err)
+	}
+	return samples, nil
+}
+
+type promSample struct {
+	labels labels.Labels
+	name   string
+	mType  string
+	value  float64
+}
+
+// parsePrometheusText reads the Prometheus text exposition format and keeps
+// counters, gauges and untyped samples; histogram and summary series and
+// non-finite values are skipped.
+func parsePrometheusText(r io.Reader) ([]promSample, error) {
+	types := make(map[string]string)
+	samples := make([]promSample, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "#") {
+			fields := strings.Fields(text)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		s, err := parsePromSample(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+
+		s.mType = promSampleType(types, s.name)
+		if s.mType != promCounter && s.mType != promGauge && s.mType != promUntyped {
+			continue
+		}
+		samples = append(samples, s)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+	return samples, nil
+}
+
+func promSampleType(types map[string]string, name string) string {
+	if t, ok := types[name]; ok {
+		return t
+	}
+	// OpenMetrics declares counters without the _total suffix.
+	if base, ok := strings.CutSuffix(name, "_total"); ok && types[base] == promCounter {
+		return promCounter
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count", "_created"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			if _, known := types[base]; known {
+				return types[base]
+			}
+		}
+	}
+	return promUntyped
+}
+
+func parsePromSample(text string) (promSample, error) {
+	s := promSample{labels: labels.Labels{}}
+
+	end := strings.IndexAny(text, "{ \t")
+	if end <= 0 {
+		return s, fmt.Errorf("malformed sample %q", text)
+	}
+	s.name = text[:end]
+	rest := text[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		closing := labelSetEnd(rest)
+		if closing < 0 {
+			return s, fmt.Errorf("unterminated label set in %q", text)
+		}
+
+		_, lbls, err := labels.Parse(s.name + rest[:closing+1])
+		if err != nil {
+			return s, fmt.Errorf("malformed labels: %w", err)
+		}
+		s.labels = lbls
+		rest = rest[closing+1:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return s, fmt.Errorf("malformed sample %q", text)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("malformed value in %q: %w", text, err)
+	}
+	s.value = value
+
+	return s, nil
+}
+
+// labelSetEnd returns the index of the brace closing the label set that
+// rest starts with, skipping braces inside quoted label values.
+func labelSetEnd(rest string) int {
+	quoted := false
+	for i := 1; i < len(rest); i++ {
+		switch {
+		case rest[i] == '\\' && quoted:
+			i++
+		case rest[i] == '"':
+			quoted = !quoted
+		case rest[i] == '}' && !quoted:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/labels"
+)
+
+func TestParsePrometheusText(t *testing.T) {
+	input := `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 1027 1395066363000
+http_requests_total{method="POST",code="400"} 3
+# TYPE queue_length gauge
+queue_length 12.5
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.2
+rpc_duration_seconds_sum 17.3
+rpc_duration_seconds_count 120
+# TYPE jobs counter
+jobs_total 7
+temperature{room="a \"quoted\" {room}"} 21
+free_memory NaN
+`
+
+	samples, err := parsePrometheusText(strings.NewReader(input))
+	assert.Equal(t, err, nil)
+
+	want := []promSample{
+		{name: "http_requests_total", labels: labels.Labels{"method": "GET", "code": "200"}, mType: promCounter, value: 1027},
+		{name: "http_requests_total", labels: labels.Labels{"method": "POST", "code": "400"}, mType: promCounter, value: 3},
+		{name: "queue_length", labels: labels.Labels{}, mType: promGauge, value: 12.5},
+		{name: "jobs_total", labels: labels.Labels{}, mType: promCounter, value: 7},
+		{name: "temperature", labels: labels.Labels{"room": `a "quoted" {room}`}, mType: promUntyped, value: 21},
+	}
+	assert.Equal(t, samples, want)
+}
+
+func TestParsePrometheusText_Malformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "missing value", input: "queue_length\n"},
+		{name: "bad value", input: "queue_length twelve\n"},
+		{name: "unterminated labels", input: "queue_length{a=\"b\" 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePrometheusText(strings.NewReader(tt.input))
+			assert.NotEqual(t, err, nil)
+		})
+	}
+}
+
+func TestCumulative(t *testing.T) {
+	c := newCumulative(false)
+
+	assert.Equal(t, c.delta("jobs", 10), int64(0))
+	assert.Equal(t, c.delta("jobs", 15), int64(5))
+	assert.Equal(t, c.delta("jobs", 15), int64(0))
+	assert.Equal(t, c.delta("jobs", 4), int64(4))
+}
@@ -4,12 +4,45 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type ServerCfg struct {
 	Host       string `json:"host"`
 	StorageCfg StorageCfg
+	Alerting   AlertingCfg
+	// HistoryRetention is how long timestamped samples are kept in memory
+	// for rates and queries.
+	HistoryRetention time.Duration
+	// AdminTokens maps the bearer tokens accepted by the admin API to the
+	// names of their holders, which go to the audit log.
+	AdminTokens map[string]string
+	Expiry      ExpiryCfg
+}
+
+// ExpiryCfg hides series that have not been updated for their TTL and deletes
+// them DeleteAfter later. Series no rule applies to never expire.
+type ExpiryCfg struct {
+	Rules       []TTLRule
+	DeleteAfter time.Duration
+}
+
+// TTLRule applies to the series of MType, or of any type if it is empty,
+// whose IDs start with Prefix. A zero TTL keeps the series forever.
+type TTLRule struct {
+	MType  string
+	Prefix string
+	TTL    time.Duration
+}
+
+// AlertingCfg points to the alerting rules and receivers file; an empty path
+// turns alerting off.
+type AlertingCfg struct {
+	RulesPath string
+	Interval  time.Duration
 }
 
 type StorageCfg struct {
@@ -20,9 +53,115 @@ type StorageCfg struct {
 }
 
 type AgentCfg struct {
-	Host           string `json:"host"`
-	PollInterval   uint64 `json:"poll_interval"`
-	ReportInterval uint64 `json:"report_interval"`
+	Labels         map[string]string `json:"labels"`
+	ID             string            `json:"id"`
+	Host           string            `json:"host"`
+	Key            string            `json:"key"`
+	PullAddress    string            `json:"pull_address"`
+	OutboxPath     string            `json:"outbox_path"`
+	PollInterval   uint64            `json:"poll_interval"`
+	ReportInterval uint64            `json:"report_interval"`
+	OutboxSize     int               `json:"outbox_size"`
+	RetryMax       int               `json:"retry_max"`
+	RetryWaitMin   Duration          `json:"retry_wait_min"`
+	RetryWaitMax   Duration          `json:"retry_wait_max"`
+	// ShutdownTimeout bounds the final collection and send on SIGINT/SIGTERM.
+	ShutdownTimeout Duration   `json:"shutdown_timeout"`
+	Collectors      []string   `json:"collectors"`
+	Exec            ExecCfg    `json:"exec"`
+	Scrape          ScrapeCfg  `json:"scrape"`
+	LogTail         LogTailCfg `json:"logtail"`
+	Process         ProcessCfg `json:"process"`
+	Cgroup          CgroupCfg  `json:"cgroup"`
+	// Aggregate maps a gauge name, or "*" for all gauges, to the statistics
+	// (min, max, avg, count, last) reported for it per report window.
+	Aggregate map[string][]string `json:"aggregate"`
+	// Destinations are the servers metrics are reported to. When none are
+	// configured Host and Key make up the only one.
+	Destinations []DestinationCfg `json:"destinations"`
+	// Delivery is "fanout" to send every batch to all destinations or
+	// "failover" to send it to the first one that accepts it.
+	Delivery string `json:"delivery"`
+}
+
+const (
+	DeliveryFanout   = "fanout"
+	DeliveryFailover = "failover"
+
+	ModeBatch  = "batch"
+	ModeSingle = "single"
+)
+
+// DestinationCfg describes one server the agent reports to. Empty Key and
+// retry settings are taken from the agent-wide ones.
+type DestinationCfg struct {
+	RetryMax     *int     `json:"retry_max"`
+	Name         string   `json:"name"`
+	Host         string   `json:"host"`
+	Mode         string   `json:"mode"`
+	Key          string   `json:"key"`
+	RetryWaitMin Duration `json:"retry_wait_min"`
+	RetryWaitMax Duration `json:"retry_wait_max"`
+	Gzip         bool     `json:"gzip"`
+}
+
+type ExecCfg struct {
+	Commands []ExecCommandCfg `json:"commands"`
+	Interval Duration         `json:"interval"`
+	Timeout  Duration         `json:"timeout"`
+}
+
+type ExecCommandCfg struct {
+	Name    string   `json:"name"`
+	Format  string   `json:"format"`
+	Command []string `json:"command"`
+}
+
+type ScrapeCfg struct {
+	Targets  []ScrapeTargetCfg `json:"targets"`
+	Interval Duration          `json:"interval"`
+	Timeout  Duration          `json:"timeout"`
+}
+
+type ScrapeTargetCfg struct {
+	URL    string `json:"url"`
+	Prefix string `json:"prefix"`
+}
+
+type LogTailCfg struct {
+	Files    []LogFileCfg `json:"files"`
+	Interval Duration     `json:"interval"`
+}
+
+type LogFileCfg struct {
+	Path  string       `json:"path"`
+	Rules []LogRuleCfg `json:"rules"`
+}
+
+// ProcessCfg selects processes by PID or by a regexp matched against the
+// process name or command line.
+type ProcessCfg struct {
+	PIDs     []int    `json:"pids"`
+	Names    []string `json:"names"`
+	ProcRoot string   `json:"proc_root"`
+	Interval Duration `json:"interval"`
+}
+
+// CgroupCfg lists cgroup v2 directories to report, either absolute or
+// relative to Root. With no paths the agent reports its own cgroup.
+type CgroupCfg struct {
+	Paths    []string `json:"paths"`
+	Root     string   `json:"root"`
+	Interval Duration `json:"interval"`
+}
+
+// LogRuleCfg turns lines matching Regexp into a metric. A counter counts the
+// lines, or adds up the named Group when it is set; a gauge is set to Group.
+type LogRuleCfg struct {
+	Metric string `json:"metric"`
+	Type   string `json:"type"`
+	Regexp string `json:"regexp"`
+	Group  string `json:"group"`
 }
 
 func NewServerConfig() (ServerCfg, error) {
@@ -34,12 +173,21 @@ func NewServerConfig() (ServerCfg, error) {
 	const defaultFileStoragePath = "/tmp/metrics-db.json" // пустое значение отключает функцию записи на диск
 	const defaultRestore = true
 	const defaultDatabaseDSN = ""
+	const defaultAlertInterval = 15 * time.Second
+	const defaultHistoryRetention = time.Hour
+	const defaultMetricDeleteAfter = 24 * time.Hour
 
 	var flagRunAddr string
 	var flagStoreInterval uint64
 	var flagFileStoragePath string
 	var flagRestore bool
 	var flagDatabaseDSN string
+	var flagAlertRules string
+	var flagAlertInterval time.Duration
+	var flagHistoryRetention time.Duration
+	var flagAdminTokens string
+	var flagMetricTTL string
+	var flagMetricDeleteAfter time.Duration
 
 	flag.StringVar(&flagRunAddr, "a", defaultRunAddr, "address and port to run server")
 	flag.BoolVar(&flagRestore, "r", defaultRestore, "restore data from file at server start")
@@ -48,6 +196,16 @@ func NewServerConfig() (ServerCfg, error) {
 	flag.StringVar(&flagFileStoragePath, "f", defaultFileStoragePath, "file storage path")
 	flag.StringVar(&flagDatabaseDSN, "d", defaultDatabaseDSN, "DB connection string")
 
+	flag.StringVar(&flagAlertRules, "alert-rules", "", "alerting config file with rules and webhook receivers")
+	flag.DurationVar(&flagAlertInterval, "alert-interval", defaultAlertInterval, "alert rules evaluation interval")
+	flag.DurationVar(&flagHistoryRetention, "history-retention", defaultHistoryRetention,
+		"how long metric samples are kept for rates and queries")
+	flag.StringVar(&flagAdminTokens, "admin-tokens", "", "admin API tokens as name:token pairs separated by commas")
+	flag.StringVar(&flagMetricTTL, "metric-ttl", "",
+		"TTL rules such as gauge=1h,counter:Poll=24h,*:disk.=30m; stale series are hidden")
+	flag.DurationVar(&flagMetricDeleteAfter, "metric-delete-after", defaultMetricDeleteAfter,
+		"how long stale series are kept hidden before they are deleted")
+
 	flag.Parse()
 
 	cfg.Host = flagRunAddr
@@ -89,6 +247,73 @@ func NewServerConfig() (ServerCfg, error) {
 	}
 
 	cfg.StorageCfg = storageCfg
+
+	cfg.Alerting.RulesPath = flagAlertRules
+	envAlertRules, ok := os.LookupEnv("ALERT_RULES")
+	if ok {
+		cfg.Alerting.RulesPath = envAlertRules
+	}
+
+	cfg.Alerting.Interval = flagAlertInterval
+	envAlertInterval, ok := os.LookupEnv("ALERT_INTERVAL")
+	if ok {
+		interval, err := time.ParseDuration(envAlertInterval)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as an alert interval: %w", envAlertInterval, err)
+		}
+		cfg.Alerting.Interval = interval
+	}
+	if cfg.Alerting.Interval <= 0 {
+		return cfg, fmt.Errorf("alert interval must be positive, got %s", cfg.Alerting.Interval)
+	}
+
+	cfg.HistoryRetention = flagHistoryRetention
+	envHistoryRetention, ok := os.LookupEnv("HISTORY_RETENTION")
+	if ok {
+		retention, err := time.ParseDuration(envHistoryRetention)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as a history retention: %w", envHistoryRetention, err)
+		}
+		cfg.HistoryRetention = retention
+	}
+	if cfg.HistoryRetention <= 0 {
+		return cfg, fmt.Errorf("history retention must be positive, got %s", cfg.HistoryRetention)
+	}
+
+	adminTokens := flagAdminTokens
+	envAdminTokens, ok := os.LookupEnv("ADMIN_TOKENS")
+	if ok {
+		adminTokens = envAdminTokens
+	}
+	tokens, err := parseAdminTokens(adminTokens)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.AdminTokens = tokens
+
+	metricTTL := flagMetricTTL
+	envMetricTTL, ok := os.LookupEnv("METRIC_TTL")
+	if ok {
+		metricTTL = envMetricTTL
+	}
+	cfg.Expiry.Rules, err = parseTTLRules(metricTTL)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.Expiry.DeleteAfter = flagMetricDeleteAfter
+	envMetricDeleteAfter, ok := os.LookupEnv("METRIC_DELETE_AFTER")
+	if ok {
+		deleteAfter, err := time.ParseDuration(envMetricDeleteAfter)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as a metric delete delay: %w", envMetricDeleteAfter, err)
+		}
+		cfg.Expiry.DeleteAfter = deleteAfter
+	}
+	if cfg.Expiry.DeleteAfter < 0 {
+		return cfg, fmt.Errorf("metric delete delay must not be negative, got %s", cfg.Expiry.DeleteAfter)
+	}
+
 	return cfg, nil
 }
 
@@ -98,44 +323,384 @@ func NewAgentConfig() (AgentCfg, error) {
 	const defaultRunAddr = "localhost:8080"
 	const defaultReportInterval uint64 = 10
 	const defaultPollInterval uint64 = 2
+	const defaultOutboxPath = "" // очередь неотправленных метрик включается заданием каталога
+	const defaultOutboxSize = 100
+	const defaultRetryMax = 3
+	const defaultRetryWaitMin = 1 * time.Second
+	const defaultRetryWaitMax = 5 * time.Second
+	const defaultCollectors = "memstats"
+	const defaultShutdownTimeout = 10 * time.Second
+	const defaultDelivery = DeliveryFanout
 
+	var flagConfigPath string
 	var flagRunAddr string
 	var flagReportInterval uint64
 	var flagPollInterval uint64
+	var flagOutboxPath string
+	var flagOutboxSize int
+	var flagRetryMax int
+	var flagRetryWaitMin time.Duration
+	var flagRetryWaitMax time.Duration
+	var flagCollectors string
+	var flagPullAddress string
+	var flagAgentID string
+	var flagLabels string
+	var flagKey string
+	var flagShutdownTimeout time.Duration
+	var flagDelivery string
 
-	var ReportInterval uint64
-	var PollInterval uint64
-
+	flag.StringVar(&flagConfigPath, "c", "", "path to a JSON config file")
 	flag.StringVar(&flagRunAddr, "a", defaultRunAddr, "address and port to run server")
 	flag.Uint64Var(&flagPollInterval, "p", defaultPollInterval, "data poll interval")
 	flag.Uint64Var(&flagReportInterval, "r", defaultReportInterval, "data report interval")
+	flag.StringVar(&flagOutboxPath, "o", defaultOutboxPath, "directory for batches that failed to send")
+	flag.IntVar(&flagOutboxSize, "q", defaultOutboxSize, "max number of batches kept in the outbox")
+	flag.IntVar(&flagRetryMax, "retry-max", defaultRetryMax, "max number of retries for a failed request")
+	flag.DurationVar(&flagRetryWaitMin, "retry-wait-min", defaultRetryWaitMin, "min delay between retries")
+	flag.DurationVar(&flagRetryWaitMax, "retry-wait-max", defaultRetryWaitMax, "max delay between retries")
+	flag.StringVar(&flagCollectors, "collectors", defaultCollectors, "comma-separated list of enabled collectors")
+	flag.StringVar(&flagPullAddress, "l", "", "address to serve the agent's own metrics on, disabled if empty")
+	flag.StringVar(&flagAgentID, "id", "", "agent ID sent with every batch, hostname if empty")
+	flag.StringVar(&flagLabels, "labels", "", "comma-separated key=value labels sent with every batch")
+	flag.StringVar(&flagKey, "k", "", "key to sign sent data with HMAC-SHA256")
+	flag.DurationVar(&flagShutdownTimeout, "shutdown-timeout", defaultShutdownTimeout,
+		"deadline for the final send on shutdown")
+	flag.StringVar(&flagDelivery, "delivery", defaultDelivery, "how to use several destinations: fanout or failover")
 	flag.Parse()
 
-	cfg.Host = flagRunAddr
+	// Значения из файла конфигурации имеют наименьший приоритет:
+	// их перекрывают явно заданные флаги, а флаги — переменные окружения.
+	configPath := flagConfigPath
+	envConfigPath, ok := os.LookupEnv("CONFIG")
+	if ok {
+		configPath = envConfigPath
+	}
+	if configPath != "" {
+		if err := loadConfigFile(configPath, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	passed := passedFlags()
+
+	if passed["a"] || cfg.Host == "" {
+		cfg.Host = flagRunAddr
+	}
 	envRunAddr, ok := os.LookupEnv("ADDRESS")
 	if ok {
 		cfg.Host = envRunAddr
 	}
 
-	ReportInterval = flagReportInterval
+	if passed["r"] || cfg.ReportInterval == 0 {
+		cfg.ReportInterval = flagReportInterval
+	}
 	envReportInterval, ok := os.LookupEnv("REPORT_INTERVAL")
 	if ok {
-		ReportInterval, err := strconv.ParseUint(envReportInterval, 10, 64)
+		reportInterval, err := strconv.ParseUint(envReportInterval, 10, 64)
 		if err != nil {
-			return cfg, fmt.Errorf("failed to parse %d as a report interval value: %w", ReportInterval, err)
+			return cfg, fmt.Errorf("failed to parse %s as a report interval value: %w", envReportInterval, err)
 		}
+		cfg.ReportInterval = reportInterval
 	}
-	cfg.ReportInterval = ReportInterval
 
-	PollInterval = flagPollInterval
+	if passed["p"] || cfg.PollInterval == 0 {
+		cfg.PollInterval = flagPollInterval
+	}
 	envPollInterval, ok := os.LookupEnv("POLL_INTERVAL")
 	if ok {
-		PollInterval, err := strconv.ParseUint(envPollInterval, 10, 64)
+		pollInterval, err := strconv.ParseUint(envPollInterval, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as a poll interval value: %w", envPollInterval, err)
+		}
+		cfg.PollInterval = pollInterval
+	}
+
+	if passed["o"] || cfg.OutboxPath == "" {
+		cfg.OutboxPath = flagOutboxPath
+	}
+	envOutboxPath, ok := os.LookupEnv("OUTBOX_PATH")
+	if ok {
+		cfg.OutboxPath = envOutboxPath
+	}
+
+	if passed["q"] || cfg.OutboxSize == 0 {
+		cfg.OutboxSize = flagOutboxSize
+	}
+	envOutboxSize, ok := os.LookupEnv("OUTBOX_SIZE")
+	if ok {
+		outboxSize, err := strconv.Atoi(envOutboxSize)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as an outbox size value: %w", envOutboxSize, err)
+		}
+		cfg.OutboxSize = outboxSize
+	}
+
+	if passed["retry-max"] || cfg.RetryMax == 0 {
+		cfg.RetryMax = flagRetryMax
+	}
+	envRetryMax, ok := os.LookupEnv("RETRY_MAX")
+	if ok {
+		retryMax, err := strconv.Atoi(envRetryMax)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as a retry max value: %w", envRetryMax, err)
+		}
+		cfg.RetryMax = retryMax
+	}
+
+	if passed["retry-wait-min"] || cfg.RetryWaitMin.Duration == 0 {
+		cfg.RetryWaitMin.Duration = flagRetryWaitMin
+	}
+	envRetryWaitMin, ok := os.LookupEnv("RETRY_WAIT_MIN")
+	if ok {
+		retryWaitMin, err := time.ParseDuration(envRetryWaitMin)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as a retry wait min value: %w", envRetryWaitMin, err)
+		}
+		cfg.RetryWaitMin.Duration = retryWaitMin
+	}
+
+	if passed["retry-wait-max"] || cfg.RetryWaitMax.Duration == 0 {
+		cfg.RetryWaitMax.Duration = flagRetryWaitMax
+	}
+	envRetryWaitMax, ok := os.LookupEnv("RETRY_WAIT_MAX")
+	if ok {
+		retryWaitMax, err := time.ParseDuration(envRetryWaitMax)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as a retry wait max value: %w", envRetryWaitMax, err)
+		}
+		cfg.RetryWaitMax.Duration = retryWaitMax
+	}
+
+	if passed["collectors"] || cfg.Collectors == nil {
+		cfg.Collectors = splitList(flagCollectors)
+	}
+	envCollectors, ok := os.LookupEnv("COLLECTORS")
+	if ok {
+		cfg.Collectors = splitList(envCollectors)
+	}
+
+	if passed["l"] || cfg.PullAddress == "" {
+		cfg.PullAddress = flagPullAddress
+	}
+	envPullAddress, ok := os.LookupEnv("PULL_ADDRESS")
+	if ok {
+		cfg.PullAddress = envPullAddress
+	}
+
+	if passed["id"] || cfg.ID == "" {
+		cfg.ID = flagAgentID
+	}
+	envAgentID, ok := os.LookupEnv("AGENT_ID")
+	if ok {
+		cfg.ID = envAgentID
+	}
+	if cfg.ID == "" {
+		hostname, err := os.Hostname()
 		if err != nil {
-			return cfg, fmt.Errorf("failed to parse %d as a poll interval value: %w", PollInterval, err)
+			return cfg, fmt.Errorf("failed to get hostname for the agent ID: %w", err)
 		}
+		cfg.ID = hostname
+	}
+
+	if passed["labels"] || cfg.Labels == nil {
+		labels, err := parseLabels(flagLabels)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Labels = labels
+	}
+	envLabels, ok := os.LookupEnv("AGENT_LABELS")
+	if ok {
+		labels, err := parseLabels(envLabels)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Labels = labels
+	}
+
+	if passed["k"] || cfg.Key == "" {
+		cfg.Key = flagKey
+	}
+	envKey, ok := os.LookupEnv("KEY")
+	if ok {
+		cfg.Key = envKey
+	}
+
+	if passed["shutdown-timeout"] || cfg.ShutdownTimeout.Duration == 0 {
+		cfg.ShutdownTimeout.Duration = flagShutdownTimeout
+	}
+	envShutdownTimeout, ok := os.LookupEnv("SHUTDOWN_TIMEOUT")
+	if ok {
+		shutdownTimeout, err := time.ParseDuration(envShutdownTimeout)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s as a shutdown timeout value: %w", envShutdownTimeout, err)
+		}
+		cfg.ShutdownTimeout.Duration = shutdownTimeout
+	}
+
+	if passed["delivery"] || cfg.Delivery == "" {
+		cfg.Delivery = flagDelivery
+	}
+	envDelivery, ok := os.LookupEnv("DELIVERY")
+	if ok {
+		cfg.Delivery = envDelivery
+	}
+
+	if cfg.RetryWaitMin.Duration > cfg.RetryWaitMax.Duration {
+		return cfg, fmt.Errorf("retry wait min %s is greater than retry wait max %s", cfg.RetryWaitMin, cfg.RetryWaitMax)
+	}
+
+	if err := setDestinations(&cfg); err != nil {
+		return cfg, err
 	}
-	cfg.PollInterval = PollInterval
 
 	return cfg, nil
 }
+
+// setDestinations fills in destination defaults from the agent-wide
+// settings, falling back to a single destination at Host.
+func setDestinations(cfg *AgentCfg) error {
+	switch cfg.Delivery {
+	case DeliveryFanout, DeliveryFailover:
+	default:
+		return fmt.Errorf("unknown delivery %q, want %s or %s", cfg.Delivery, DeliveryFanout, DeliveryFailover)
+	}
+
+	if len(cfg.Destinations) == 0 {
+		cfg.Destinations = []DestinationCfg{{Name: "default", Host: cfg.Host}}
+	}
+
+	names := make(map[string]bool, len(cfg.Destinations))
+	for i := range cfg.Destinations {
+		d := &cfg.Destinations[i]
+		if d.Host == "" {
+			return fmt.Errorf("destination %d has no host", i)
+		}
+		if d.Name == "" {
+			d.Name = destinationName(d.Host)
+		}
+		// Имя используется как каталог очереди неотправленных метрик.
+		if filepath.Base(d.Name) != d.Name || d.Name == "." || d.Name == ".." {
+			return fmt.Errorf("destination name %q must not contain path separators", d.Name)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("duplicate destination name %q", d.Name)
+		}
+		names[d.Name] = true
+
+		if d.Mode == "" {
+			d.Mode = ModeBatch
+		}
+		if d.Mode != ModeBatch && d.Mode != ModeSingle {
+			return fmt.Errorf("destination %q has unknown mode %q, want %s or %s", d.Name, d.Mode, ModeBatch, ModeSingle)
+		}
+		if d.Key == "" {
+			d.Key = cfg.Key
+		}
+		if d.RetryMax == nil {
+			retryMax := cfg.RetryMax
+			d.RetryMax = &retryMax
+		}
+		if d.RetryWaitMin.Duration == 0 {
+			d.RetryWaitMin = cfg.RetryWaitMin
+		}
+		if d.RetryWaitMax.Duration == 0 {
+			d.RetryWaitMax = cfg.RetryWaitMax
+		}
+		if d.RetryWaitMin.Duration > d.RetryWaitMax.Duration {
+			return fmt.Errorf("destination %q retry wait min %s is greater than retry wait max %s",
+				d.Name, d.RetryWaitMin, d.RetryWaitMax)
+		}
+	}
+
+	return nil
+}
+
+// destinationName turns a host such as http://metrics.local:8080/api into
+// metrics.local_8080_api, which is safe to use as a directory name.
+func destinationName(host string) string {
+	if _, rest, ok := strings.Cut(host, "://"); ok {
+		host = rest
+	}
+
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.Trim(host, "/"))
+
+	if strings.Trim(name, ".") == "" {
+		return "default"
+	}
+	return name
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTTLRules reads selector=ttl pairs, where the selector is a metric type
+// or "*" for any, optionally followed by a colon and an ID prefix.
+func parseTTLRules(value string) ([]TTLRule, error) {
+	rules := make([]TTLRule, 0)
+	for _, item := range splitList(value) {
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("failed to parse TTL rule %q, want selector=ttl", item)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(item[i+1:]))
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("failed to parse TTL of rule %q", item)
+		}
+
+		mType, prefix, _ := strings.Cut(strings.TrimSpace(item[:i]), ":")
+		switch mType {
+		case "*":
+			mType = ""
+		case "gauge", "counter":
+		default:
+			return nil, fmt.Errorf("unknown metric type %q in TTL rule %q", mType, item)
+		}
+		rules = append(rules, TTLRule{MType: mType, Prefix: prefix, TTL: ttl})
+	}
+	return rules, nil
+}
+
+// parseAdminTokens reads name:token pairs; the token is what follows the
+// first colon, so it may contain colons itself.
+func parseAdminTokens(value string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, item := range splitList(value) {
+		name, token, ok := strings.Cut(item, ":")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("failed to parse admin token %q, want name:token", name)
+		}
+		if _, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("admin token of %s is already used by %s", name, tokens[token])
+		}
+		tokens[token] = name
+	}
+	return tokens, nil
+}
+
+func parseLabels(value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, item := range splitList(value) {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("failed to parse %q as a key=value label", item)
+		}
+		labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return labels, nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert"
+)
+
+func TestDestinationName(t *testing.T) {
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{
+			name: "host and port",
+			host: "localhost:8080",
+			want: "localhost_8080",
+		},
+		{
+			name: "full URL",
+			host: "https://metrics.local:8443/api/",
+			want: "metrics.local_8443_api",
+		},
+		{
+			name: "nothing left of the host",
+			host: "http://..",
+			want: "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, destinationName(tt.host), tt.want)
+		})
+	}
+}
+
+func TestSetDestinations_DefaultNames(t *testing.T) {
+	cfg := AgentCfg{
+		Delivery: DeliveryFailover,
+		Destinations: []DestinationCfg{
+			{Host: "http://primary.local:8080"},
+			{Host: "http://standby.local:8080/metrics"},
+		},
+	}
+	if err := setDestinations(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, cfg.Destinations[0].Name, "primary.local_8080")
+	assert.Equal(t, cfg.Destinations[1].Name, "standby.local_8080_metrics")
+}
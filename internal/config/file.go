@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Duration is a time.Duration written as "10s" or "1m30s" in config files.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(d.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal duration: %w", err)
+	}
+	return data, nil
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("failed to unmarshal duration: %w", err)
+	}
+
+	switch v := value.(type) {
+	case float64:
+		d.Duration = time.Duration(v) * time.Second
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse %q as a duration: %w", v, err)
+		}
+		d.Duration = parsed
+	default:
+		return errors.New(`duration must be a string like "10s" or a number of seconds`)
+	}
+	return nil
+}
+
+func loadConfigFile(path string, cfg any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("cannot unmarshal config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func passedFlags() map[string]bool {
+	passed := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+	return passed
+}
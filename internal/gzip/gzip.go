@@ -2,6 +2,7 @@ package gzip
 
 import (
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -61,16 +62,20 @@ func NewCompressReader(r io.ReadCloser) (*CompressReader, error) {
 
 func (c CompressReader) Read(p []byte) (n int, err error) {
 	bytesRead, err := c.zr.Read(p)
-	if err != nil {
-		return 0, fmt.Errorf("cannot read from compressReader: %w", err)
+	if errors.Is(err, io.EOF) {
+		// io.EOF must be returned as is, readers like io.ReadAll compare it with ==.
+		return bytesRead, io.EOF
 	}
-	if err := c.zr.Close(); err != nil {
-		return 0, fmt.Errorf("cannot close compressReader: %w", err)
+	if err != nil {
+		return bytesRead, fmt.Errorf("cannot read from compressReader: %w", err)
 	}
 	return bytesRead, nil
 }
 
 func (c *CompressReader) Close() error {
+	if err := c.zr.Close(); err != nil {
+		return fmt.Errorf("cannot close compressReader: %w", err)
+	}
 	if err := c.r.Close(); err != nil {
 		return fmt.Errorf("cannot close compressReader: %w", err)
 	}
@@ -0,0 +1,114 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Labels are encoded into the metric ID in the Prometheus style,
+// e.g. HeapAlloc{agent="host-1",dc="eu"}, so that the storage and the wire
+// format stay plain name/value pairs. Label names are always sorted, which
+// makes the encoding of a given series unique.
+type Labels map[string]string
+
+func Format(name string, lbls Labels) string {
+	if len(lbls) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(lbls))
+	for k := range lbls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(lbls[k]))
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// Parse splits an ID produced by Format back into the name and labels.
+// IDs without braces are plain names with no labels.
+func Parse(id string) (string, Labels, error) {
+	open := strings.IndexByte(id, '{')
+	if open < 0 {
+		return id, Labels{}, nil
+	}
+	if !strings.HasSuffix(id, "}") {
+		return "", nil, fmt.Errorf("unterminated label set in %q", id)
+	}
+
+	name := id[:open]
+	lbls := Labels{}
+	rest := id[open+1 : len(id)-1]
+
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return "", nil, fmt.Errorf("malformed label in %q", id)
+		}
+		key := strings.TrimSpace(rest[:eq])
+
+		quoted, err := strconv.QuotedPrefix(rest[eq+1:])
+		if err != nil {
+			return "", nil, fmt.Errorf("malformed value of label %q in %q: %w", key, id, err)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", nil, fmt.Errorf("malformed value of label %q in %q: %w", key, id, err)
+		}
+		lbls[key] = value
+
+		rest = rest[eq+1+len(quoted):]
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return "", nil, errors.New("labels must be separated by commas in " + id)
+		}
+		rest = rest[1:]
+	}
+
+	return name, lbls, nil
+}
+
+// Merge returns a new label set where labels from extra override base.
+func Merge(base, extra Labels) Labels {
+	merged := make(Labels, len(base)+len(extra))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Match reports whether the series id has the given name and carries every
+// label of matchers; other labels of the series are ignored.
+func Match(id, name string, matchers Labels) bool {
+	idName, lbls, err := Parse(id)
+	if err != nil || idName != name {
+		return false
+	}
+	for k, v := range matchers {
+		if value, ok := lbls[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
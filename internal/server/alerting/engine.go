@@ -0,0 +1,288 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/storage"
+)
+
+const (
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+
+	stateKey = "alerting"
+	// resolvedRetention is how long a resolved alert stays in the listing.
+	resolvedRetention = 15 * time.Minute
+)
+
+// Alert is the state of one rule for one series.
+type Alert struct {
+	ActiveAt   time.Time  `json:"active_at"`
+	FiredAt    *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Rule       string     `json:"rule"`
+	Expr       string     `json:"expr"`
+	Series     string     `json:"series"`
+	MType      string     `json:"type"`
+	State      string     `json:"state"`
+	Value      float64    `json:"value"`
+}
+
+type rule struct {
+	Rule
+	expr expr
+}
+
+// Engine evaluates rules against the storage and keeps alert states, which it
+// saves to the storage on every change, and notifies receivers about them.
+type Engine struct {
+	store     storage.Storage
+	history   *storage.History
+	expiry    *storage.Expiry
+	logger    *zap.Logger
+	mu        *sync.Mutex
+	alerts    map[string]*Alert
+	rules     []rule
+	receivers []*receiver
+}
+
+// NewEngine expects cfg as returned by Load; rate() rules are computed from
+// the history. Series stale by expiry, which may be nil, are not evaluated,
+// so their alerts resolve.
+func NewEngine(cfg Config, store storage.Storage, history *storage.History, expiry *storage.Expiry,
+	lg *zap.Logger) (*Engine, error) {
+	e := &Engine{
+		store:   store,
+		history: history,
+		expiry:  expiry,
+		logger:  lg,
+		mu:      &sync.Mutex{},
+		alerts:  make(map[string]*Alert),
+	}
+
+	for _, r := range cfg.Receivers {
+		e.receivers = append(e.receivers, newReceiver(r, lg))
+	}
+	for _, r := range cfg.Rules {
+		parsed, err := parseExpr(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		e.rules = append(e.rules, rule{Rule: r, expr: parsed})
+	}
+
+	if err := e.restore(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Run evaluates the rules every interval and sends notifications until ctx
+// is done.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	for _, r := range e.receivers {
+		go r.run(ctx)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := e.Evaluate(now); err != nil {
+				e.logger.Info("failed to evaluate alert rules:", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Evaluate checks every rule against the stored metrics as of now.
+func (e *Engine) Evaluate(now time.Time) error {
+	stored, err := e.store.ListMetrics()
+	if err != nil {
+		return fmt.Errorf("failed to list metrics: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	changed := false
+	seen := make(map[string]bool)
+	for _, r := range e.rules {
+		for _, m := range stored {
+			if !r.expr.matches(m.ID) || (e.expiry != nil && e.expiry.Stale(m, now)) {
+				continue
+			}
+
+			key := alertKey(r.Name, m.MType, m.ID)
+			seen[key] = true
+			value := m.Float64()
+			if r.expr.rate {
+				rate, ok := e.history.Rate(m.MType, m.ID, r.expr.window, now)
+				if !ok {
+					// Для скорости нужны два замера; до второго состояние не меняем.
+					continue
+				}
+				value = rate.Rate
+			}
+
+			if r.expr.holds(value) {
+				changed = e.activate(r, m.MType, m.ID, value, now) || changed
+			} else {
+				changed = e.deactivate(key, now) || changed
+			}
+		}
+	}
+
+	for key, a := range e.alerts {
+		switch {
+		case !seen[key] && a.State != StateResolved:
+			changed = e.deactivate(key, now) || changed
+		case a.State == StateResolved && now.Sub(*a.ResolvedAt) > resolvedRetention:
+			delete(e.alerts, key)
+			changed = true
+		}
+	}
+
+	if changed {
+		e.save()
+	}
+	return nil
+}
+
+// Alerts returns the current alerts sorted by rule and series.
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		alerts = append(alerts, *a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		if alerts[i].Series != alerts[j].Series {
+			return alerts[i].Series < alerts[j].Series
+		}
+		return alerts[i].MType < alerts[j].MType
+	})
+	return alerts
+}
+
+func (e *Engine) activate(r rule, mType, series string, value float64, now time.Time) bool {
+	key := alertKey(r.Name, mType, series)
+	a, ok := e.alerts[key]
+	if !ok || a.State == StateResolved {
+		a = &Alert{Rule: r.Name, Expr: r.Expr, Series: series, MType: mType, State: StatePending, ActiveAt: now}
+		e.alerts[key] = a
+		ok = false
+	}
+	a.Value = value
+
+	if a.State == StatePending && now.Sub(a.ActiveAt) >= r.expr.forPeriod {
+		firedAt := now
+		a.State, a.FiredAt = StateFiring, &firedAt
+		e.logger.Info("alert firing:", zap.String("rule", a.Rule), zap.String("series", a.Series),
+			zap.Float64("value", value))
+		e.notify(EventFiring, a)
+		return true
+	}
+	if !ok {
+		e.notify(EventPending, a)
+	}
+	return !ok
+}
+
+func (e *Engine) deactivate(key string, now time.Time) bool {
+	a, ok := e.alerts[key]
+	if !ok {
+		return false
+	}
+
+	switch a.State {
+	case StatePending:
+		delete(e.alerts, key)
+		return true
+	case StateFiring:
+		resolvedAt := now
+		a.State, a.ResolvedAt = StateResolved, &resolvedAt
+		e.logger.Info("alert resolved:", zap.String("rule", a.Rule), zap.String("series", a.Series))
+		e.notify(EventResolved, a)
+		return true
+	}
+	return false
+}
+
+func (e *Engine) notify(event string, a *Alert) {
+	for _, r := range e.receivers {
+		r.enqueue(Notification{Event: event, Alert: *a})
+	}
+}
+
+func (e *Engine) save() {
+	alerts := make([]*Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		alerts = append(alerts, a)
+	}
+
+	data, err := json.Marshal(alerts)
+	if err != nil {
+		e.logger.Info("failed to JSON encode alert states:", zap.Error(err))
+		return
+	}
+	if err := e.store.SaveState(stateKey, data); err != nil {
+		e.logger.Info("failed to save alert states:", zap.Error(err))
+	}
+}
+
+// restore loads alert states saved before a restart, dropping the ones whose
+// rule is gone or has a different expression now, and resolved ones without
+// the time they resolved at, which could never be cleaned up.
+func (e *Engine) restore() error {
+	data, err := e.store.LoadState(stateKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrStateNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to load alert states: %w", err)
+	}
+
+	var alerts []*Alert
+	if err := json.Unmarshal(data, &alerts); err != nil {
+		return fmt.Errorf("failed to decode alert states: %w", err)
+	}
+
+	exprs := make(map[string]string, len(e.rules))
+	for _, r := range e.rules {
+		exprs[r.Name] = r.Expr
+	}
+	for _, a := range alerts {
+		if expr, ok := exprs[a.Rule]; !ok || expr != a.Expr {
+			continue
+		}
+		if a.State == StateResolved && a.ResolvedAt == nil {
+			continue
+		}
+		e.alerts[alertKey(a.Rule, a.MType, a.Series)] = a
+	}
+	return nil
+}
+
+// alertKey tells apart a gauge and a counter that share an ID.
+func alertKey(rule, mType, series string) string {
+	return rule + "\x00" + mType + "\x00" + series
+}
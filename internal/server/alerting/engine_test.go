@@ -0,0 +1,250 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []Rule
+		wantErr bool
+	}{
+		{
+			name: "names default to expressions",
+			file: `{"rules": [{"name": "HeapTooBig", "expr": "HeapAlloc > 1e9 for 2m"},
+				{"expr": "rate(PollCount{agent=\"a1\"}[2m]) == 0 for 5m"}]}`,
+			want: []Rule{
+				{Name: "HeapTooBig", Expr: "HeapAlloc > 1e9 for 2m"},
+				{Name: `rate(PollCount{agent="a1"}[2m]) == 0 for 5m`, Expr: `rate(PollCount{agent="a1"}[2m]) == 0 for 5m`},
+			},
+		},
+		{
+			name:    "unknown operator",
+			file:    `{"rules": [{"expr": "HeapAlloc => 1"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "bad rate window",
+			file:    `{"rules": [{"expr": "rate(PollCount[0s]) > 1"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "bad for duration",
+			file:    `{"rules": [{"expr": "HeapAlloc > 1 for 2 minutes"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "receiver without url",
+			file:    `{"rules": [{"expr": "X > 1"}], "receivers": [{"name": "chat"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate names",
+			file:    `{"rules": [{"name": "a", "expr": "X > 1"}, {"name": "a", "expr": "Y > 1"}]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Load(path)
+			assert.Equal(t, err != nil, tt.wantErr)
+			if !tt.wantErr {
+				assert.Equal(t, got.Rules, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []Rule{
+		{Name: "HeapTooBig", Expr: `HeapAlloc{agent="a1"} > 100 for 2m`},
+		{Name: "AgentSilent", Expr: "rate(PollCount[1m]) == 0 for 1m"},
+	}
+	history := storage.NewHistory(time.Hour)
+	e, err := NewEngine(Config{Rules: rules}, store, history, nil, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		updates [][3]string
+		want    map[string]string
+	}{
+		{
+			updates: [][3]string{
+				{storage.GaugeType, `HeapAlloc{agent="a1"}`, "150"},
+				{storage.GaugeType, `HeapAlloc{agent="a2"}`, "500"},
+				{storage.CounterType, `PollCount{agent="a1"}`, "5"},
+			},
+			want: map[string]string{`HeapTooBig HeapAlloc{agent="a1"}`: StatePending},
+		},
+		{
+			updates: [][3]string{{storage.CounterType, `PollCount{agent="a1"}`, "5"}},
+			want:    map[string]string{`HeapTooBig HeapAlloc{agent="a1"}`: StatePending},
+		},
+		{
+			want: map[string]string{
+				`HeapTooBig HeapAlloc{agent="a1"}`:  StateFiring,
+				`AgentSilent PollCount{agent="a1"}`: StatePending,
+			},
+		},
+		{
+			updates: [][3]string{{storage.GaugeType, `HeapAlloc{agent="a1"}`, "50"}},
+			want: map[string]string{
+				`HeapTooBig HeapAlloc{agent="a1"}`:  StateResolved,
+				`AgentSilent PollCount{agent="a1"}`: StateFiring,
+			},
+		},
+		{
+			updates: [][3]string{{storage.CounterType, `PollCount{agent="a1"}`, "1"}},
+			want: map[string]string{
+				`HeapTooBig HeapAlloc{agent="a1"}`:  StateResolved,
+				`AgentSilent PollCount{agent="a1"}`: StateResolved,
+			},
+		},
+	}
+	for i, step := range steps {
+		now := start.Add(time.Duration(i) * time.Minute)
+		for _, u := range step.updates {
+			if _, err := store.SaveMetric(u[0], u[1], u[2]); err != nil {
+				t.Fatal(err)
+			}
+			current, err := store.GetMetric(u[0], u[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := strconv.ParseFloat(current, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			history.Record(u[0], u[1], value, now)
+		}
+		if err := e.Evaluate(now); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, alertStates(e), step.want)
+	}
+
+	// Состояния переживают перезапуск, а ушедшее правило забывается.
+	restarted, err := NewEngine(Config{Rules: rules[:1]}, store, history, nil, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, alertStates(restarted), map[string]string{`HeapTooBig HeapAlloc{agent="a1"}`: StateResolved})
+
+	if err := restarted.Evaluate(start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, alertStates(restarted), map[string]string{})
+}
+
+func alertStates(e *Engine) map[string]string {
+	states := make(map[string]string)
+	for _, a := range e.Alerts() {
+		states[a.Rule+" "+a.Series] = a.State
+	}
+	return states
+}
+
+func TestEngine_EvaluateSkipsStale(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{`HeapAlloc{agent="gone"}`, `HeapAlloc{agent="live"}`} {
+		if _, err := store.SaveMetric(storage.GaugeType, id, "150"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	store.Updated[storage.GaugeType][`HeapAlloc{agent="gone"}`] = now.Add(-2 * time.Hour)
+
+	expiry := storage.NewExpiry(config.ExpiryCfg{Rules: []config.TTLRule{{MType: storage.GaugeType, TTL: time.Hour}}})
+	rules := []Rule{{Name: "HeapTooBig", Expr: "HeapAlloc > 100 for 2m"}}
+	e, err := NewEngine(Config{Rules: rules}, store, storage.NewHistory(time.Hour), expiry, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Evaluate(now); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, alertStates(e), map[string]string{`HeapTooBig HeapAlloc{agent="live"}`: StatePending})
+}
+
+func TestEngine_EvaluateKeepsTypesApart(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SaveMetric(storage.GaugeType, "Requests", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SaveMetric(storage.CounterType, "Requests", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []Rule{{Name: "TooMany", Expr: "Requests > 5 for 2m"}}
+	e, err := NewEngine(Config{Rules: rules}, store, storage.NewHistory(time.Hour), nil, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := e.Evaluate(start); err != nil {
+		t.Fatal(err)
+	}
+	// Счётчик ниже порога и не должен сбрасывать ожидание у датчика с тем же ID.
+	if err := e.Evaluate(start.Add(2 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts := e.Alerts()
+	assert.Equal(t, len(alerts), 1)
+	assert.Equal(t, alerts[0].MType, storage.GaugeType)
+	assert.Equal(t, alerts[0].State, StateFiring)
+}
+
+func TestEngine_RestoreDropsResolvedWithoutTime(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := `[{"rule":"HeapTooBig","expr":"HeapAlloc > 100","series":"HeapAlloc","type":"gauge","state":"resolved"},` +
+		`{"rule":"HeapTooBig","expr":"HeapAlloc > 100","series":"Sys","type":"gauge","state":"pending"}]`
+	if err := store.SaveState(stateKey, []byte(saved)); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []Rule{{Name: "HeapTooBig", Expr: "HeapAlloc > 100"}}
+	e, err := NewEngine(Config{Rules: rules}, store, storage.NewHistory(time.Hour), nil, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, alertStates(e), map[string]string{"HeapTooBig Sys": StatePending})
+
+	assert.Equal(t, e.Evaluate(time.Now()), nil)
+	assert.Equal(t, alertStates(e), map[string]string{})
+}
@@ -0,0 +1,228 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/pkg/client"
+)
+
+const (
+	EventPending  = StatePending
+	EventFiring   = StateFiring
+	EventResolved = StateResolved
+
+	receiverQueue       = 100
+	defaultDedup        = 5 * time.Minute
+	defaultRetryMax     = 3
+	defaultRetryWaitMin = 1 * time.Second
+	defaultRetryWaitMax = 30 * time.Second
+	rateLimitWindow     = time.Minute
+	notificationTimeout = 2 * time.Minute
+)
+
+// Receiver is a webhook that alert notifications are POSTed to as JSON. With
+// a key the body is signed like agent requests, with HMAC-SHA256 in the
+// HashSHA256 header. Events choose among pending (a series crossed a rule),
+// firing and resolved; by default only firing and resolved are sent.
+// The same event for the same alert is sent once per dedup period, and at
+// most rate_limit notifications a minute go out (0 means no limit).
+type Receiver struct {
+	RetryMax     *int            `json:"retry_max"`
+	Name         string          `json:"name"`
+	URL          string          `json:"url"`
+	Key          string          `json:"key"`
+	Events       []string        `json:"events"`
+	Dedup        config.Duration `json:"dedup"`
+	RetryWaitMin config.Duration `json:"retry_wait_min"`
+	RetryWaitMax config.Duration `json:"retry_wait_max"`
+	RateLimit    int             `json:"rate_limit"`
+}
+
+// Notification is the webhook payload: the alert as listed by /api/v1/alerts
+// and the event that triggered it.
+type Notification struct {
+	Event string `json:"event"`
+	Alert
+}
+
+type receiver struct {
+	Receiver
+	client  *http.Client
+	logger  *zap.Logger
+	queue   chan Notification
+	events  map[string]bool
+	sentAt  map[string]time.Time
+	window  time.Time
+	inQuota int
+}
+
+func setReceiverDefaults(r *Receiver) error {
+	if r.URL == "" {
+		return fmt.Errorf("receiver %q has no url", r.Name)
+	}
+	if r.Name == "" {
+		r.Name = r.URL
+	}
+	if len(r.Events) == 0 {
+		r.Events = []string{EventFiring, EventResolved}
+	}
+	for _, event := range r.Events {
+		switch event {
+		case EventPending, EventFiring, EventResolved:
+		default:
+			return fmt.Errorf("receiver %q: unknown event %q", r.Name, event)
+		}
+	}
+	if r.RateLimit < 0 {
+		return fmt.Errorf("receiver %q: negative rate limit", r.Name)
+	}
+	if r.Dedup.Duration == 0 {
+		r.Dedup.Duration = defaultDedup
+	}
+	if r.RetryMax == nil {
+		retryMax := defaultRetryMax
+		r.RetryMax = &retryMax
+	}
+	if r.RetryWaitMin.Duration == 0 {
+		r.RetryWaitMin.Duration = defaultRetryWaitMin
+	}
+	if r.RetryWaitMax.Duration == 0 {
+		r.RetryWaitMax.Duration = defaultRetryWaitMax
+	}
+	if r.RetryWaitMin.Duration > r.RetryWaitMax.Duration {
+		return fmt.Errorf("receiver %q: retry wait min %s is greater than retry wait max %s",
+			r.Name, r.RetryWaitMin, r.RetryWaitMax)
+	}
+	return nil
+}
+
+func newReceiver(r Receiver, lg *zap.Logger) *receiver {
+	retClient := retryablehttp.NewClient()
+	retClient.Logger = nil
+	retClient.Backoff = client.Backoff
+	retClient.CheckRetry = client.CheckRetry
+	retClient.RetryMax = *r.RetryMax
+	retClient.RetryWaitMin = r.RetryWaitMin.Duration
+	retClient.RetryWaitMax = r.RetryWaitMax.Duration
+
+	events := make(map[string]bool, len(r.Events))
+	for _, event := range r.Events {
+		events[event] = true
+	}
+
+	return &receiver{
+		Receiver: r,
+		client:   retClient.StandardClient(),
+		logger:   lg.With(zap.String("receiver", r.Name)),
+		queue:    make(chan Notification, receiverQueue),
+		events:   events,
+		sentAt:   make(map[string]time.Time),
+	}
+}
+
+// enqueue never blocks the rule evaluation: when the receiver is too far
+// behind the notification is dropped.
+func (r *receiver) enqueue(n Notification) {
+	if !r.events[n.Event] {
+		return
+	}
+	select {
+	case r.queue <- n:
+	default:
+		r.logger.Info("receiver queue is full, dropping notification:",
+			zap.String("rule", n.Rule), zap.String("series", n.Series), zap.String("event", n.Event))
+	}
+}
+
+func (r *receiver) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-r.queue:
+			r.deliver(ctx, n, time.Now())
+		}
+	}
+}
+
+func (r *receiver) deliver(ctx context.Context, n Notification, now time.Time) {
+	key := n.Event + "\x00" + alertKey(n.Rule, n.MType, n.Series)
+	if sentAt, ok := r.sentAt[key]; ok && now.Sub(sentAt) < r.Dedup.Duration {
+		return
+	}
+	for k, sentAt := range r.sentAt {
+		if now.Sub(sentAt) >= r.Dedup.Duration {
+			delete(r.sentAt, k)
+		}
+	}
+
+	if r.RateLimit > 0 {
+		if now.Sub(r.window) >= rateLimitWindow {
+			r.window, r.inQuota = now, 0
+		}
+		if r.inQuota >= r.RateLimit {
+			r.logger.Info("receiver rate limit exceeded, dropping notification:",
+				zap.String("rule", n.Rule), zap.String("series", n.Series), zap.String("event", n.Event))
+			return
+		}
+		r.inQuota++
+	}
+
+	if err := r.post(ctx, n); err != nil {
+		r.logger.Info("failed to send notification:", zap.String("rule", n.Rule),
+			zap.String("series", n.Series), zap.String("event", n.Event), zap.Error(err))
+		return
+	}
+	r.sentAt[key] = now
+}
+
+func (r *receiver) post(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to JSON encode notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create a request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Key != "" {
+		h := hmac.New(sha256.New, []byte(r.Key))
+		h.Write(body)
+		req.Header.Set(client.HashHeader, hex.EncodeToString(h.Sum(nil)))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to do a request: %w", err)
+	}
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return errors.Join(fmt.Errorf("error copying response body: %w", err), resp.Body.Close())
+	}
+	if err := resp.Body.Close(); err != nil {
+		return fmt.Errorf("error closing response body: %w", err)
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return &client.StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
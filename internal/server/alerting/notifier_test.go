@@ -0,0 +1,154 @@
+package alerting
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/storage"
+	"go-yandex-metrics/pkg/client"
+)
+
+type webhook struct {
+	*httptest.Server
+	received chan Notification
+	requests *atomic.Int64
+}
+
+// newWebhook checks the signature of every notification, when given a key,
+// and fails the first failFirst requests with 503.
+func newWebhook(t *testing.T, key string, failFirst int64) *webhook {
+	t.Helper()
+
+	w := &webhook{received: make(chan Notification, 10), requests: &atomic.Int64{}}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if key != "" {
+			h := hmac.New(sha256.New, []byte(key))
+			h.Write(body)
+			if r.Header.Get(client.HashHeader) != hex.EncodeToString(h.Sum(nil)) {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		if w.requests.Add(1) <= failFirst {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var n Notification
+		if err := json.Unmarshal(body, &n); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.received <- n
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+func testReceiver(t *testing.T, r Receiver) Receiver {
+	t.Helper()
+
+	retryMax := 2
+	r.RetryMax = &retryMax
+	r.RetryWaitMin = config.Duration{Duration: time.Millisecond}
+	r.RetryWaitMax = config.Duration{Duration: 10 * time.Millisecond}
+	if err := setReceiverDefaults(&r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestEngine_Notify(t *testing.T) {
+	hook := newWebhook(t, "secret", 1)
+
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{
+		Rules:     []Rule{{Name: "HeapTooBig", Expr: "HeapAlloc > 100"}},
+		Receivers: []Receiver{testReceiver(t, Receiver{Name: "chat", URL: hook.URL, Key: "secret"})},
+	}
+	e, err := NewEngine(cfg, store, storage.NewHistory(time.Hour), nil, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx, time.Hour)
+
+	now := time.Now()
+	for i, value := range []string{"150", "50"} {
+		if _, err := store.SaveMetric(storage.GaugeType, "HeapAlloc", value); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Evaluate(now.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var events []string
+	for range 2 {
+		select {
+		case n := <-hook.received:
+			assert.Equal(t, n.Rule, "HeapTooBig")
+			assert.Equal(t, n.Series, "HeapAlloc")
+			events = append(events, n.Event)
+		case <-time.After(5 * time.Second):
+			t.Fatal("notification was not delivered")
+		}
+	}
+	// Первое событие дошло со второй попытки, pending по умолчанию не шлётся.
+	assert.Equal(t, events, []string{EventFiring, EventResolved})
+	assert.Equal(t, hook.requests.Load(), int64(3))
+}
+
+func TestReceiver_Deliver(t *testing.T) {
+	hook := newWebhook(t, "", 0)
+	r := newReceiver(testReceiver(t, Receiver{URL: hook.URL, RateLimit: 2}), zap.NewNop())
+
+	notification := func(series string) Notification {
+		return Notification{Event: EventFiring, Alert: Alert{Rule: "HeapTooBig", Series: series}}
+	}
+	now := time.Now()
+	steps := []struct {
+		n    Notification
+		at   time.Time
+		sent bool
+	}{
+		{n: notification("a1"), at: now, sent: true},
+		{n: notification("a1"), at: now.Add(time.Second), sent: false},
+		{n: notification("a2"), at: now.Add(2 * time.Second), sent: true},
+		{n: notification("a3"), at: now.Add(3 * time.Second), sent: false},
+		{n: notification("a3"), at: now.Add(time.Minute), sent: true},
+		{n: notification("a1"), at: now.Add(6 * time.Minute), sent: true},
+	}
+	for _, step := range steps {
+		before := hook.requests.Load()
+		r.deliver(context.Background(), step.n, step.at)
+		assert.Equal(t, hook.requests.Load()-before == 1, step.sent)
+		if step.sent {
+			<-hook.received
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go-yandex-metrics/internal/labels"
+)
+
+// Config is the alerting config file:
+//
+//	{"rules": [
+//	  {"name": "HeapTooBig", "expr": "HeapAlloc > 1e9 for 2m"},
+//	  {"name": "AgentSilent", "expr": "rate(PollCount{agent=\"a1\"}[2m]) == 0 for 5m"}
+//	],
+//	"receivers": [{"name": "chat", "url": "http://chat.local/hook", "key": "secret"}]}
+type Config struct {
+	Rules     []Rule     `json:"rules"`
+	Receivers []Receiver `json:"receivers"`
+}
+
+// Rule is an alerting rule. The expression compares a series, or its
+// per-second rate over a window (5m by default), with a number and has to
+// hold for the optional for duration before the alert fires. A name without
+// labels matches every series of that name.
+type Rule struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+type expr struct {
+	matchers  labels.Labels
+	name      string
+	op        string
+	threshold float64
+	rate      bool
+	window    time.Duration
+	forPeriod time.Duration
+}
+
+const defaultRateWindow = 5 * time.Minute
+
+var exprRe = regexp.MustCompile(
+	`^\s*(?:(rate)\(\s*([^\s(){}\[\]<>=!]+(?:\{[^}]*\})?)(?:\[(\S+?)\])?\s*\)|([^\s(){}<>=!]+(?:\{[^}]*\})?))` +
+		`\s*(>=|<=|==|!=|>|<)\s*(\S+)(?:\s+for\s+(\S+))?\s*$`)
+
+// Load reads and validates an alerting config file.
+func Load(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("cannot read alerting config file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("cannot unmarshal alerting config file %s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(cfg.Rules))
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if _, err := parseExpr(r.Expr); err != nil {
+			return cfg, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		if r.Name == "" {
+			r.Name = r.Expr
+		}
+		if names[r.Name] {
+			return cfg, fmt.Errorf("rule %d: duplicate rule name %q", i+1, r.Name)
+		}
+		names[r.Name] = true
+	}
+
+	receivers := make(map[string]bool, len(cfg.Receivers))
+	for i := range cfg.Receivers {
+		r := &cfg.Receivers[i]
+		if err := setReceiverDefaults(r); err != nil {
+			return cfg, fmt.Errorf("receiver %d: %w", i+1, err)
+		}
+		if receivers[r.Name] {
+			return cfg, fmt.Errorf("receiver %d: duplicate receiver name %q", i+1, r.Name)
+		}
+		receivers[r.Name] = true
+	}
+	return cfg, nil
+}
+
+func parseExpr(s string) (expr, error) {
+	m := exprRe.FindStringSubmatch(s)
+	if m == nil {
+		return expr{}, fmt.Errorf("cannot parse expression %q, want e.g. \"HeapAlloc > 1e9 for 2m\"", s)
+	}
+
+	e := expr{rate: m[1] != "", op: m[5], window: defaultRateWindow}
+
+	selector := m[2] + m[4]
+	name, matchers, err := labels.Parse(selector)
+	if err != nil {
+		return expr{}, fmt.Errorf("bad selector in %q: %w", s, err)
+	}
+	if name == "" {
+		return expr{}, fmt.Errorf("no metric name in %q", s)
+	}
+	e.name, e.matchers = name, matchers
+
+	if m[3] != "" {
+		e.window, err = time.ParseDuration(m[3])
+		if err != nil || e.window <= 0 {
+			return expr{}, fmt.Errorf("bad rate window in %q", s)
+		}
+	}
+
+	e.threshold, err = strconv.ParseFloat(m[6], 64)
+	if err != nil {
+		return expr{}, fmt.Errorf("bad threshold in %q: %w", s, err)
+	}
+
+	if m[7] != "" {
+		e.forPeriod, err = time.ParseDuration(m[7])
+		if err != nil {
+			return expr{}, fmt.Errorf("bad for duration in %q: %w", s, err)
+		}
+		if e.forPeriod < 0 {
+			return expr{}, errors.New("negative for duration in " + s)
+		}
+	}
+	return e, nil
+}
+
+// matches reports whether a stored series ID is selected by the expression.
+func (e expr) matches(id string) bool {
+	return labels.Match(id, e.name, e.matchers)
+}
+
+func (e expr) holds(value float64) bool {
+	switch e.op {
+	case ">":
+		return value > e.threshold
+	case ">=":
+		return value >= e.threshold
+	case "<":
+		return value < e.threshold
+	case "<=":
+		return value <= e.threshold
+	case "==":
+		return value == e.threshold
+	default:
+		return value != e.threshold
+	}
+}
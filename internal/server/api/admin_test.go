@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestServer_AdminHandlers(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range [][3]string{
+		{GaugeType, "Alloc", "3"},
+		{CounterType, "PollCount", "7"},
+		{CounterType, `PollCount{agent="a1"}`, "5"},
+	} {
+		if _, err := store.SaveMetric(m[0], m[1], m[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, store)
+	s.cfg.AdminTokens = map[string]string{"secret": "ops"}
+	s.history.Record(GaugeType, "Alloc", 3, time.Now())
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		token    string
+		wantCode int
+	}{
+		{
+			name:     "no token",
+			method:   http.MethodDelete,
+			url:      "/api/v1/metrics/gauge/Alloc",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong token",
+			method:   http.MethodDelete,
+			url:      "/api/v1/metrics/gauge/Alloc",
+			token:    "guess",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "delete a gauge",
+			method:   http.MethodDelete,
+			url:      "/api/v1/metrics/gauge/Alloc",
+			token:    "secret",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "delete it again",
+			method:   http.MethodDelete,
+			url:      "/api/v1/metrics/gauge/Alloc",
+			token:    "secret",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "unknown type",
+			method:   http.MethodDelete,
+			url:      "/api/v1/metrics/histogram/Alloc",
+			token:    "secret",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "reset a labelled counter",
+			method:   http.MethodPost,
+			url:      "/api/v1/metrics/counter/PollCount%7Bagent=%22a1%22%7D/reset",
+			token:    "secret",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "gauges cannot be reset",
+			method:   http.MethodPost,
+			url:      "/api/v1/metrics/gauge/Alloc/reset",
+			token:    "secret",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "reset a missing counter",
+			method:   http.MethodPost,
+			url:      "/api/v1/metrics/counter/Missing/reset",
+			token:    "secret",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, http.NoBody)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+
+	_, err = store.GetMetric(GaugeType, "Alloc")
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, len(s.history.Series(GaugeType)), 0)
+
+	value, err := store.GetMetric(CounterType, `PollCount{agent="a1"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, value, "0")
+	value, err = store.GetMetric(CounterType, "PollCount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, value, "7")
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/server/alerting"
+)
+
+// AlertsHandler lists pending, firing and recently resolved alerts; the state
+// query parameter keeps only alerts in that state.
+func (s *Server) AlertsHandler(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "", alerting.StatePending, alerting.StateFiring, alerting.StateResolved:
+	default:
+		http.Error(w, fmt.Sprintf("unknown alert state %q", state), http.StatusBadRequest)
+		return
+	}
+
+	alerts := make([]alerting.Alert, 0)
+	if s.alerts != nil {
+		for _, a := range s.alerts.Alerts() {
+			if state == "" || a.State == state {
+				alerts = append(alerts, a)
+			}
+		}
+	}
+
+	data, err := json.Marshal(alerts)
+	if err != nil {
+		s.logger.Info("failed to JSON encode alerts:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeStr, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		s.logger.Info("failed to write to ResponseWriter:", zap.Error(err))
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/storage"
+)
+
+const defaultDashboardRefresh = 60
+
+//go:embed templates/dashboard.html
+var templatesDir embed.FS
+
+type dashboard struct {
+	Generated  time.Time
+	Query      string
+	RateWindow string
+	Sections   []dashboardSection
+	Refresh    int
+}
+
+type dashboardSection struct {
+	Title string
+	Type  string
+	Rows  []dashboardRow
+	Rates bool
+}
+
+type dashboardRow struct {
+	UpdatedAt time.Time
+	ID        string
+	Value     string
+	Rate      string
+}
+
+func createTemplate() (*template.Template, error) {
+	t, err := template.ParseFS(templatesDir, "templates/dashboard.html")
+	if err != nil {
+		return nil, fmt.Errorf("an error occured parsing dashboard template: %w", err)
+	}
+	return t, nil
+}
+
+// DashboardHandler renders the index page: a table per metric type, narrowed
+// down by the q query parameter, with counter rates over the last 5m and the
+// time every series was last updated. The
+// page follows /stream for updates and reloads the tables every refresh
+// seconds (0 turns it off).
+func (s *Server) DashboardHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	refresh := defaultDashboardRefresh
+	if value := r.URL.Query().Get("refresh"); value != "" {
+		n, err := queryInt(value)
+		if err != nil {
+			http.Error(w, "bad refresh interval: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		refresh = n
+	}
+
+	stored, err := s.liveMetrics()
+	if err != nil {
+		s.logger.Info("an error occured getting a list of metrics:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sortMetrics(stored)
+
+	data := dashboard{
+		Generated:  time.Now(),
+		Query:      query,
+		RateWindow: defaultRateWindow.String(),
+		Refresh:    refresh,
+		Sections: []dashboardSection{
+			{Title: "Gauges", Type: GaugeType},
+			{Title: "Counters", Type: CounterType, Rates: true},
+		},
+	}
+	for _, m := range stored {
+		if !strings.Contains(strings.ToLower(m.ID), strings.ToLower(query)) {
+			continue
+		}
+		for i := range data.Sections {
+			section := &data.Sections[i]
+			if section.Type != m.MType {
+				continue
+			}
+			row := dashboardRow{ID: m.ID, Value: formatValue(m), UpdatedAt: m.UpdatedAt}
+			if section.Rates {
+				if rate, ok := s.history.Rate(m.MType, m.ID, defaultRateWindow, data.Generated); ok {
+					row.Rate = strconv.FormatFloat(rate.Rate, 'g', 4, 64)
+				}
+			}
+			section.Rows = append(section.Rows, row)
+		}
+	}
+
+	var doc bytes.Buffer
+	if err := s.tpl.Execute(&doc, data); err != nil {
+		s.logger.Info("an error occured processing template data:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeStr, "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(doc.Bytes()); err != nil {
+		s.logger.Info("an error occured writing to browser:", zap.Error(err))
+	}
+}
+
+func formatValue(m storage.Metric) string {
+	if m.MType == CounterType {
+		return strconv.FormatInt(m.Delta, 10)
+	}
+	return strconv.FormatFloat(m.Value, 'f', -1, 64)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestServer_DashboardHandler(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SaveMetric(GaugeType, `<b>Heap</b>`, "1.5"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SaveMetric(CounterType, "PollCount", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl, err := createTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := storage.NewHistory(time.Hour)
+	history.Record(CounterType, "PollCount", 1, time.Now().Add(-2*time.Minute))
+	history.Record(CounterType, "PollCount", 3, time.Now().Add(-time.Minute))
+	s := &Server{store: store, tpl: tpl, history: history, logger: zap.NewNop()}
+	pollUpdated := store.Updated[CounterType]["PollCount"].Format("2006-01-02 15:04:05")
+
+	tests := []struct {
+		name    string
+		url     string
+		want    []string
+		notWant []string
+		code    int
+	}{
+		{
+			name:    "metric names are escaped",
+			url:     "/",
+			want:    []string{"<td>&lt;b&gt;Heap&lt;/b&gt;</td>", "<td>PollCount</td>", `<td class="value">0.01667</td>`},
+			notWant: []string{"<b>Heap</b>"},
+			code:    http.StatusOK,
+		},
+		{
+			name: "search narrows the tables down",
+			url:  "/?q=poll&refresh=0",
+			want: []string{
+				"<td>PollCount</td>", "var refresh =  0 ;",
+				`<th data-col="3">Updated</th>`, pollUpdated + "</time>",
+			},
+			notWant: []string{"Heap"},
+			code:    http.StatusOK,
+		},
+		{
+			name: "bad refresh interval",
+			url:  "/?refresh=soon",
+			code: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.IndexHandler(rr, httptest.NewRequest(http.MethodGet, tt.url, http.NoBody))
+
+			assert.Equal(t, rr.Code, tt.code)
+			body := rr.Body.String()
+			for _, w := range tt.want {
+				assert.Equal(t, strings.Contains(body, w), true)
+			}
+			for _, w := range tt.notWant {
+				assert.Equal(t, strings.Contains(body, w), false)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/storage"
+)
+
+// liveMetrics lists the stored metrics except the stale ones.
+func (s *Server) liveMetrics() ([]storage.Metric, error) {
+	stored, err := s.store.ListMetrics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metrics: %w", err)
+	}
+	if s.expiry == nil {
+		return stored, nil
+	}
+
+	now := time.Now()
+	live := stored[:0]
+	for _, m := range stored {
+		if !s.expiry.Stale(m, now) {
+			live = append(live, m)
+		}
+	}
+	return live, nil
+}
+
+// liveHistory is the history the way queries and searches see it: series
+// that liveMetrics leaves out are left out of Series as well. A nil live set
+// means expiry is off and every series is live.
+type liveHistory struct {
+	*storage.History
+	live map[seriesKey]bool
+}
+
+type seriesKey struct {
+	mType string
+	id    string
+}
+
+func (h liveHistory) Series(mType string) []string {
+	ids := h.History.Series(mType)
+	if h.live == nil {
+		return ids
+	}
+
+	live := ids[:0]
+	for _, id := range ids {
+		if h.live[seriesKey{mType: mType, id: id}] {
+			live = append(live, id)
+		}
+	}
+	return live
+}
+
+func (s *Server) liveHistory() (liveHistory, error) {
+	h := liveHistory{History: s.history}
+	if s.expiry == nil {
+		return h, nil
+	}
+
+	stored, err := s.liveMetrics()
+	if err != nil {
+		return h, err
+	}
+	h.live = make(map[seriesKey]bool, len(stored))
+	for _, m := range stored {
+		h.live[seriesKey{mType: m.MType, id: m.ID}] = true
+	}
+	return h, nil
+}
+
+func (s *Server) expireMetrics() {
+	ticker := time.NewTicker(time.Minute)
+	for now := range ticker.C {
+		s.deleteExpired(now)
+	}
+}
+
+// deleteExpired removes the expired series from the storage and the history,
+// keeping the ones saved again since they were listed.
+func (s *Server) deleteExpired(now time.Time) {
+	stored, err := s.store.ListMetrics()
+	if err != nil {
+		s.logger.Info("failed to list metrics for expiry:", zap.Error(err))
+		return
+	}
+
+	for _, m := range stored {
+		if !s.expiry.Expired(m, now) {
+			continue
+		}
+		// Серию могли обновить после ListMetrics, поэтому срок проверяется
+		// ещё раз в самом удалении.
+		before, _ := s.expiry.ExpiredBefore(m.MType, m.ID, now)
+		err := s.store.DeleteStaleMetric(m.MType, m.ID, before)
+		if errors.Is(err, storage.ErrMetricNotFound) {
+			continue
+		}
+		if err != nil {
+			s.logger.Info("failed to delete expired metric:", zap.Error(err))
+			continue
+		}
+		s.history.Delete(m.MType, m.ID)
+		s.logger.Info("expired metric deleted:",
+			zap.String("type", m.MType),
+			zap.String("name", m.ID),
+			zap.Time("updated_at", m.UpdatedAt))
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestServer_Expiry(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range [][3]string{
+		{GaugeType, `Alloc{agent="gone"}`, "1"},
+		{GaugeType, `Alloc{agent="idle"}`, "2"},
+		{GaugeType, `Alloc{agent="live"}`, "3"},
+		{CounterType, `PollCount{agent="gone"}`, "4"},
+	} {
+		if _, err := store.SaveMetric(m[0], m[1], m[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	store.Updated[GaugeType][`Alloc{agent="gone"}`] = now.Add(-3 * time.Hour)
+	store.Updated[GaugeType][`Alloc{agent="idle"}`] = now.Add(-90 * time.Minute)
+	store.Updated[CounterType][`PollCount{agent="gone"}`] = now.Add(-3 * time.Hour)
+
+	history := storage.NewHistory(time.Hour)
+	history.Record(GaugeType, `Alloc{agent="gone"}`, 1, now)
+
+	s := &Server{
+		store:   store,
+		history: history,
+		logger:  zap.NewNop(),
+		expiry: storage.NewExpiry(config.ExpiryCfg{
+			Rules:       []config.TTLRule{{MType: GaugeType, TTL: time.Hour}},
+			DeleteAfter: time.Hour,
+		}),
+	}
+
+	live, err := s.liveMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortMetrics(live)
+	ids := make([]string, 0, len(live))
+	for _, m := range live {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, ids, []string{`PollCount{agent="gone"}`, `Alloc{agent="live"}`})
+
+	// Запросы и поиск по истории тоже не видят устаревших серий.
+	history.Record(GaugeType, `Alloc{agent="idle"}`, 2, now)
+	history.Record(GaugeType, `Alloc{agent="live"}`, 3, now)
+	h, err := s.liveHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, h.Series(GaugeType), []string{`Alloc{agent="live"}`})
+
+	rr := httptest.NewRecorder()
+	s.QueryHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/query?q=Alloc", http.NoBody))
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, strings.Contains(rr.Body.String(), `live`), true)
+	assert.Equal(t, strings.Contains(rr.Body.String(), `idle`), false)
+
+	s.deleteExpired(now)
+
+	stored, err := store.ListMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(stored), 3)
+	_, err = store.GetMetric(GaugeType, `Alloc{agent="gone"}`)
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, history.Series(GaugeType), []string{`Alloc{agent="idle"}`, `Alloc{agent="live"}`})
+}
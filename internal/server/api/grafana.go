@@ -0,0 +1,313 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/labels"
+	"go-yandex-metrics/internal/server/query"
+)
+
+// Grafana's JSON datasource posts a time range with every request; queries
+// are evaluated at most grafanaMaxPoints times within it.
+const (
+	grafanaMaxPoints   = 1000
+	grafanaDefaultStep = 15 * time.Second
+)
+
+var errBadGrafanaRequest = errors.New("bad grafana request")
+
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+type grafanaTarget struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId"`
+	Type   string `json:"type"`
+	Hide   bool   `json:"hide"`
+}
+
+type grafanaFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+type grafanaQueryRequest struct {
+	Range         grafanaRange    `json:"range"`
+	Targets       []grafanaTarget `json:"targets"`
+	AdhocFilters  []grafanaFilter `json:"adhocFilters"`
+	IntervalMs    int64           `json:"intervalMs"`
+	MaxDataPoints int             `json:"maxDataPoints"`
+}
+
+type grafanaTimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]any         `json:"rows"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange `json:"range"`
+	Annotation struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+	} `json:"annotation"`
+}
+
+type grafanaAnnotation struct {
+	Title    string   `json:"title"`
+	Text     string   `json:"text"`
+	Tags     []string `json:"tags"`
+	Time     int64    `json:"time"`
+	TimeEnd  int64    `json:"timeEnd,omitempty"`
+	IsRegion bool     `json:"isRegion"`
+}
+
+// GrafanaTestHandler answers the connection test of the datasource.
+func (s *Server) GrafanaTestHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// GrafanaSearchHandler lists metric names and series IDs for the query
+// editor; target narrows the list down to names that contain it.
+func (s *Server) GrafanaSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var req grafanaSearchRequest
+	if err := decodeGrafanaRequest(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	history, err := s.liveHistory()
+	if err != nil {
+		s.logger.Info("an error occured getting a list of metrics:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, mType := range []string{GaugeType, CounterType} {
+		for _, id := range history.Series(mType) {
+			name, _, err := labels.Parse(id)
+			if err != nil {
+				continue
+			}
+			seen[name], seen[id] = true, true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		if strings.Contains(strings.ToLower(name), strings.ToLower(req.Target)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	s.writeGrafanaJSON(w, names)
+}
+
+// GrafanaQueryHandler evaluates every target as a /api/v1/query expression:
+// as a time series over the requested range, or as a table of values at the
+// end of the range for targets of the table type. Ad hoc filters keep the
+// series whose labels match them.
+func (s *Server) GrafanaQueryHandler(w http.ResponseWriter, r *http.Request) {
+	var req grafanaQueryRequest
+	if err := decodeGrafanaRequest(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !req.Range.To.After(req.Range.From) {
+		http.Error(w, "empty time range", http.StatusBadRequest)
+		return
+	}
+
+	history, err := s.liveHistory()
+	if err != nil {
+		s.logger.Info("an error occured getting a list of metrics:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	step := grafanaStep(req)
+	response := make([]any, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		if target.Hide || strings.TrimSpace(target.Target) == "" {
+			continue
+		}
+
+		if target.Type == "table" {
+			v, err := query.Eval(history, target.Target, req.Range.To)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s: %v", target.RefID, err), http.StatusBadRequest)
+				return
+			}
+			response = append(response, grafanaTableOf(v, req.AdhocFilters))
+			continue
+		}
+
+		series, err := query.EvalRange(history, target.Target, req.Range.From, req.Range.To, step)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s: %v", target.RefID, err), http.StatusBadRequest)
+			return
+		}
+		for _, ts := range series {
+			if !matchFilters(ts.Labels, req.AdhocFilters) {
+				continue
+			}
+			name := ts.ID()
+			if name == "" {
+				name = target.RefID
+			}
+			datapoints := make([][2]float64, 0, len(ts.Samples))
+			for _, sample := range ts.Samples {
+				datapoints = append(datapoints, [2]float64{sample.Value, float64(sample.At.UnixMilli())})
+			}
+			response = append(response, grafanaTimeSeries{Target: name, Datapoints: datapoints})
+		}
+	}
+
+	s.writeGrafanaJSON(w, response)
+}
+
+// GrafanaAnnotationsHandler marks alerts on graphs: a region from when an
+// alert fired until it was resolved, or until now if it still fires. The
+// annotation query keeps the rules whose name contains it.
+func (s *Server) GrafanaAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	var req grafanaAnnotationRequest
+	if err := decodeGrafanaRequest(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	annotations := make([]grafanaAnnotation, 0)
+	if s.alerts == nil {
+		s.writeGrafanaJSON(w, annotations)
+		return
+	}
+
+	for _, a := range s.alerts.Alerts() {
+		if a.FiredAt == nil || !strings.Contains(a.Rule, req.Annotation.Query) {
+			continue
+		}
+		end := req.Range.To
+		if a.ResolvedAt != nil {
+			end = *a.ResolvedAt
+		}
+		if a.FiredAt.After(req.Range.To) || end.Before(req.Range.From) {
+			continue
+		}
+		annotations = append(annotations, grafanaAnnotation{
+			Title:    a.Rule,
+			Text:     fmt.Sprintf("%s %s: %s = %g", a.Series, a.State, a.Expr, a.Value),
+			Tags:     []string{"alert", a.State},
+			Time:     a.FiredAt.UnixMilli(),
+			TimeEnd:  end.UnixMilli(),
+			IsRegion: true,
+		})
+	}
+
+	s.writeGrafanaJSON(w, annotations)
+}
+
+func decodeGrafanaRequest(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", errBadGrafanaRequest, err)
+	}
+	return nil
+}
+
+// grafanaStep follows the interval Grafana picked for the panel, but keeps
+// the number of evaluations within grafanaMaxPoints.
+func grafanaStep(req grafanaQueryRequest) time.Duration {
+	step := time.Duration(req.IntervalMs) * time.Millisecond
+	if step <= 0 {
+		step = grafanaDefaultStep
+	}
+
+	maxPoints := grafanaMaxPoints
+	if req.MaxDataPoints > 0 {
+		maxPoints = min(req.MaxDataPoints, maxPoints)
+	}
+	if minStep := req.Range.To.Sub(req.Range.From) / time.Duration(maxPoints); step < minStep {
+		step = minStep
+	}
+	return step
+}
+
+func grafanaTableOf(v query.Value, filters []grafanaFilter) grafanaTable {
+	table := grafanaTable{
+		Type:    "table",
+		Columns: []grafanaColumn{{Text: "Series", Type: "string"}, {Text: "Value", Type: "number"}},
+		Rows:    make([][]any, 0, len(v.Series)),
+	}
+	if v.Kind == query.KindScalar {
+		table.Rows = append(table.Rows, []any{"", v.Scalar})
+		return table
+	}
+	for _, series := range v.Series {
+		if !matchFilters(series.Labels, filters) {
+			continue
+		}
+		value := series.Value
+		if v.Kind == query.KindMatrix {
+			value = series.Samples[len(series.Samples)-1].Value
+		}
+		table.Rows = append(table.Rows, []any{series.ID(), value})
+	}
+	return table
+}
+
+func matchFilters(lbls labels.Labels, filters []grafanaFilter) bool {
+	for _, f := range filters {
+		switch f.Operator {
+		case "!=":
+			if lbls[f.Key] == f.Value {
+				return false
+			}
+		default:
+			if lbls[f.Key] != f.Value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *Server) writeGrafanaJSON(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.logger.Info("failed to JSON encode grafana response:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeStr, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		s.logger.Info("failed to write to ResponseWriter:", zap.Error(err))
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestServer_Grafana(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	history := storage.NewHistory(24 * time.Hour)
+	for i := range 3 {
+		at := start.Add(time.Duration(i) * time.Minute)
+		history.Record(GaugeType, `HeapAlloc{agent="a1"}`, float64(100*(i+1)), at)
+		history.Record(GaugeType, `HeapAlloc{agent="a2"}`, 50, at)
+	}
+	s := &Server{router: chi.NewRouter(), history: history, logger: zap.NewNop()}
+	s.routes()
+
+	rangeJSON := `"range": {"from": "2024-05-01T12:00:00Z", "to": "2024-05-01T12:02:00Z"}`
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		want     string
+		wantCode int
+	}{
+		{
+			name:     "search",
+			path:     "/grafana/search",
+			body:     `{"target": "heap"}`,
+			want:     `["HeapAlloc","HeapAlloc{agent=\"a1\"}","HeapAlloc{agent=\"a2\"}"]`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "time series with an ad hoc filter",
+			path: "/grafana/query",
+			body: `{` + rangeJSON + `, "intervalMs": 60000, "maxDataPoints": 100,
+				"targets": [{"refId": "A", "target": "HeapAlloc / 100"}],
+				"adhocFilters": [{"key": "agent", "operator": "=", "value": "a1"}]}`,
+			want:     `[{"target":"{agent=\"a1\"}","datapoints":[[1,1714564800000],[2,1714564860000],[3,1714564920000]]}]`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "table",
+			path: "/grafana/query",
+			body: `{` + rangeJSON + `, "targets": [{"refId": "A", "type": "table", "target": "sum(HeapAlloc)"}]}`,
+			want: `[{"type":"table","columns":[{"text":"Series","type":"string"},{"text":"Value","type":"number"}],` +
+				`"rows":[["",350]]}]`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "bad target",
+			path:     "/grafana/query",
+			body:     `{` + rangeJSON + `, "targets": [{"refId": "A", "target": "sum("}]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "annotations without alerting",
+			path:     "/grafana/annotations",
+			body:     `{` + rangeJSON + `, "annotation": {"name": "alerts", "query": ""}}`,
+			want:     `[]`,
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			s.router.ServeHTTP(rr, req)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, json.Valid(rr.Body.Bytes()), true)
+			assert.Equal(t, rr.Body.String(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/storage"
+)
+
+// historyStateKey is the key the history snapshot is kept under with
+// SaveState, next to the metrics, so that rates survive restarts.
+const historyStateKey = "history"
+
+func (s *Server) restoreHistory() {
+	data, err := s.store.LoadState(historyStateKey)
+	if errors.Is(err, storage.ErrStateNotFound) {
+		return
+	}
+	if err != nil {
+		s.logger.Info("failed to load history:", zap.Error(err))
+		return
+	}
+	if err := s.history.Restore(data, time.Now()); err != nil {
+		s.logger.Info("failed to restore history:", zap.Error(err))
+	}
+}
+
+func (s *Server) saveHistory() {
+	data, err := s.history.MarshalJSON()
+	if err != nil {
+		s.logger.Info("failed to snapshot history:", zap.Error(err))
+		return
+	}
+	if err := s.store.SaveState(historyStateKey, data); err != nil {
+		s.logger.Info("failed to save history:", zap.Error(err))
+	}
+}
+
+// pruneHistory drops old samples and snapshots the rest every minute.
+func (s *Server) pruneHistory() {
+	ticker := time.NewTicker(time.Minute)
+	for now := range ticker.C {
+		s.history.Prune(now)
+		s.saveHistory()
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/storage"
+)
+
+const totalCountHeader = "X-Total-Count"
+
+var errBadListQuery = errors.New("bad listing query")
+
+// ListHandler returns stored metrics as a JSON array sorted by type and ID.
+// The type, prefix, limit and offset query parameters narrow it down;
+// X-Total-Count carries the number of matches before pagination.
+func (s *Server) ListHandler(w http.ResponseWriter, r *http.Request) {
+	metrics, total, err := s.listMetrics(r)
+	if err != nil {
+		s.logger.Info("failed to list metrics:", zap.Error(err))
+		if errors.Is(err, errBadListQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		s.logger.Info("failed to JSON encode metrics:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeStr, applicationJSON)
+	w.Header().Set(totalCountHeader, strconv.Itoa(total))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		s.logger.Info("failed to write to ResponseWriter:", zap.Error(err))
+	}
+}
+
+func (s *Server) listMetrics(r *http.Request) ([]Metrics, int, error) {
+	query := r.URL.Query()
+
+	mType := query.Get("type")
+	if mType != "" && mType != GaugeType && mType != CounterType {
+		return nil, 0, fmt.Errorf("%w: unknown type %q", errBadListQuery, mType)
+	}
+	prefix := query.Get("prefix")
+
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: limit: %w", errBadListQuery, err)
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: offset: %w", errBadListQuery, err)
+	}
+
+	stored, err := s.liveMetrics()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get metrics from storage: %w", err)
+	}
+
+	selected := make([]storage.Metric, 0, len(stored))
+	for _, m := range stored {
+		if (mType == "" || m.MType == mType) && strings.HasPrefix(m.ID, prefix) {
+			selected = append(selected, m)
+		}
+	}
+	sortMetrics(selected)
+
+	total := len(selected)
+	selected = selected[min(offset, total):]
+	if limit > 0 && limit < len(selected) {
+		selected = selected[:limit]
+	}
+
+	metrics := make([]Metrics, 0, len(selected))
+	for _, m := range selected {
+		metrics = append(metrics, toMetrics(m))
+	}
+	return metrics, total, nil
+}
+
+// sortMetrics orders metrics by type and then by ID.
+func sortMetrics(metrics []storage.Metric) {
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].MType != metrics[j].MType {
+			return metrics[i].MType < metrics[j].MType
+		}
+		return metrics[i].ID < metrics[j].ID
+	})
+}
+
+func toMetrics(m storage.Metric) Metrics {
+	switch m.MType {
+	case CounterType:
+		delta := m.Delta
+		return Metrics{ID: m.ID, MType: m.MType, Delta: &delta}
+	default:
+		value := m.Value
+		return Metrics{ID: m.ID, MType: m.MType, Value: &value}
+	}
+}
+
+func queryInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q: %w", value, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%d is negative", n)
+	}
+	return n, nil
+}
+
+// wantsJSON reports whether the client asked for JSON in Accept, or, as
+// older clients do, in Content-Type.
+func wantsJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == applicationJSON {
+			return true
+		}
+	}
+	return r.Header.Get(contentTypeStr) == applicationJSON
+}
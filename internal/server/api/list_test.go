@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestServer_ListHandler(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range [][3]string{
+		{GaugeType, "HeapAlloc", "10.5"},
+		{GaugeType, "Alloc", "3"},
+		{CounterType, "PollCount", "7"},
+		{GaugeType, "HeapInuse", "2"},
+	} {
+		if _, err := store.SaveMetric(m[0], m[1], m[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &Server{store: store, logger: zap.NewNop()}
+
+	tests := []struct {
+		name      string
+		url       string
+		accept    string
+		wantIDs   []string
+		wantTotal string
+		wantCode  int
+	}{
+		{
+			name:      "all metrics sorted by type and ID",
+			url:       "/api/v1/metrics",
+			wantIDs:   []string{"PollCount", "Alloc", "HeapAlloc", "HeapInuse"},
+			wantTotal: "4",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "type, prefix and pagination",
+			url:       "/api/v1/metrics?type=gauge&prefix=Heap&limit=1&offset=1",
+			wantIDs:   []string{"HeapInuse"},
+			wantTotal: "2",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "index page negotiated by Accept",
+			url:       "/",
+			accept:    "text/html;q=0.9, application/json",
+			wantIDs:   []string{"PollCount", "Alloc", "HeapAlloc", "HeapInuse"},
+			wantTotal: "4",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:     "unknown type",
+			url:      "/api/v1/metrics?type=histogram",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "negative limit",
+			url:      "/api/v1/metrics?limit=-1",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, http.NoBody)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			if tt.url == "/" {
+				s.IndexHandler(rr, req)
+			} else {
+				s.ListHandler(rr, req)
+			}
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, rr.Header().Get(totalCountHeader), tt.wantTotal)
+
+			var got []Metrics
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(got))
+			for _, m := range got {
+				ids = append(ids, m.ID)
+			}
+			assert.Equal(t, ids, tt.wantIDs)
+		})
+	}
+}
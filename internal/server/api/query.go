@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/labels"
+	"go-yandex-metrics/internal/server/query"
+	"go-yandex-metrics/internal/storage"
+)
+
+type queryResult struct {
+	Time   time.Time     `json:"time"`
+	Scalar *float64      `json:"value,omitempty"`
+	Kind   string        `json:"type"`
+	Result []querySeries `json:"result,omitempty"`
+}
+
+type querySeries struct {
+	Value   *float64         `json:"value,omitempty"`
+	Labels  labels.Labels    `json:"labels"`
+	ID      string           `json:"id"`
+	Samples []storage.Sample `json:"samples,omitempty"`
+}
+
+// QueryHandler evaluates the q expression against the history as of time
+// (RFC 3339 or Unix seconds, now by default), for example
+//
+//	sum by (agent) (rate(PollCount[5m])) * 60
+//	HeapAlloc{agent!="a2"} / 1e6
+//	Alloc[10m]
+func (s *Server) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, "missing q parameter", http.StatusBadRequest)
+		return
+	}
+	at, err := parseTime(r.URL.Query().Get("time"), time.Now())
+	if err != nil {
+		http.Error(w, "bad time: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	history, err := s.liveHistory()
+	if err != nil {
+		s.logger.Info("an error occured getting a list of metrics:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	v, err := query.Eval(history, q, at)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := json.Marshal(toQueryResult(v, at))
+	if err != nil {
+		s.logger.Info("failed to JSON encode query result:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeStr, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		s.logger.Info("failed to write to ResponseWriter:", zap.Error(err))
+	}
+}
+
+func toQueryResult(v query.Value, at time.Time) queryResult {
+	result := queryResult{Kind: v.Kind, Time: at}
+	if v.Kind == query.KindScalar {
+		result.Scalar = &v.Scalar
+		return result
+	}
+
+	result.Result = make([]querySeries, 0, len(v.Series))
+	for _, series := range v.Series {
+		qs := querySeries{ID: series.ID(), Labels: series.Labels, Samples: series.Samples}
+		if v.Kind == query.KindVector {
+			value := series.Value
+			qs.Value = &value
+		}
+		result.Result = append(result.Result, qs)
+	}
+	return result
+}
+
+// parseTime accepts RFC 3339 or Unix seconds, with a fraction if needed.
+func parseTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return at, nil
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/labels"
+	"go-yandex-metrics/internal/storage"
+)
+
+const defaultRateWindow = 5 * time.Minute
+
+type seriesRate struct {
+	ID     string `json:"id"`
+	MType  string `json:"type"`
+	Window string `json:"window"`
+	storage.Rate
+}
+
+// RateHandler returns the per-second rate and the increase over window (5m
+// by default) of every series selected by name: either a series ID or a name
+// with some of its labels, e.g. PollCount{agent="a1"}. type defaults to
+// counter; for gauges the increase is the difference between the first and
+// the last value.
+func (s *Server) RateHandler(w http.ResponseWriter, r *http.Request) {
+	selector, err := url.PathUnescape(chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, "bad metric name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	name, matchers, err := labels.Parse(selector)
+	if err != nil {
+		http.Error(w, "bad metric name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mType := r.URL.Query().Get("type")
+	switch mType {
+	case "":
+		mType = CounterType
+	case GaugeType, CounterType:
+	default:
+		http.Error(w, fmt.Sprintf("unknown type %q", mType), http.StatusBadRequest)
+		return
+	}
+
+	window := defaultRateWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		window, err = time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			http.Error(w, fmt.Sprintf("bad window %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := s.liveHistory()
+	if err != nil {
+		s.logger.Info("an error occured getting a list of metrics:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	selected := false
+	rates := make([]seriesRate, 0)
+	for _, id := range history.Series(mType) {
+		if !labels.Match(id, name, matchers) {
+			continue
+		}
+		selected = true
+		if rate, ok := history.Rate(mType, id, window, now); ok {
+			rates = append(rates, seriesRate{ID: id, MType: mType, Window: window.String(), Rate: rate})
+		}
+	}
+	if !selected {
+		http.Error(w, fmt.Sprintf("no %s samples of %s", mType, selector), http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(rates)
+	if err != nil {
+		s.logger.Info("failed to JSON encode rates:", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeStr, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		s.logger.Info("failed to write to ResponseWriter:", zap.Error(err))
+	}
+}
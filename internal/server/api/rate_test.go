@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestServer_RateHandler(t *testing.T) {
+	now := time.Now()
+	history := storage.NewHistory(time.Hour)
+	for i, total := range []float64{100, 160, 40, 100} {
+		at := now.Add(time.Duration(i-4) * time.Minute)
+		history.Record(CounterType, `PollCount{agent="a1"}`, total, at)
+		history.Record(CounterType, `PollCount{agent="a2"}`, 10, at)
+	}
+	s := &Server{router: chi.NewRouter(), history: history, logger: zap.NewNop()}
+	s.router.Get("/api/v1/rate/{name}", s.RateHandler)
+
+	tests := []struct {
+		name         string
+		url          string
+		wantIDs      []string
+		wantIncrease float64
+		wantCode     int
+	}{
+		{
+			name:         "counter reset",
+			url:          "/api/v1/rate/" + url.PathEscape(`PollCount{agent="a1"}`) + "?window=5m",
+			wantIDs:      []string{`PollCount{agent="a1"}`},
+			wantIncrease: 60 + 40 + 60,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:     "name selects every series",
+			url:      "/api/v1/rate/PollCount",
+			wantIDs:  []string{`PollCount{agent="a1"}`, `PollCount{agent="a2"}`},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "unknown series",
+			url:      "/api/v1/rate/HeapAlloc",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "bad window",
+			url:      "/api/v1/rate/PollCount?window=-1m",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, http.NoBody))
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got []seriesRate
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(got))
+			for _, r := range got {
+				ids = append(ids, r.ID)
+			}
+			assert.Equal(t, ids, tt.wantIDs)
+			if tt.wantIncrease != 0 {
+				assert.Equal(t, got[0].Increase, tt.wantIncrease)
+			}
+		})
+	}
+}
@@ -1,12 +1,13 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -14,16 +15,21 @@ import (
 
 	"go-yandex-metrics/internal/config"
 	"go-yandex-metrics/internal/gzip"
+	"go-yandex-metrics/internal/server/alerting"
 	logger "go-yandex-metrics/internal/server/middleware"
 	"go-yandex-metrics/internal/storage"
 )
 
 type Server struct {
-	router *chi.Mux
-	tpl    *template.Template
-	logger *zap.Logger
-	store  storage.Storage
-	cfg    config.ServerCfg
+	router  *chi.Mux
+	tpl     *template.Template
+	logger  *zap.Logger
+	store   storage.Storage
+	hub     *hub
+	history *storage.History
+	alerts  *alerting.Engine
+	expiry  *storage.Expiry
+	cfg     config.ServerCfg
 }
 
 type ServerCfg struct {
@@ -49,11 +55,27 @@ func NewServer(cfg config.ServerCfg, store storage.Storage) (*Server, error) {
 	}
 
 	srv := &Server{
-		router: chi.NewRouter(),
-		tpl:    tpl,
-		logger: lg,
-		store:  store,
-		cfg:    cfg,
+		router:  chi.NewRouter(),
+		tpl:     tpl,
+		logger:  lg,
+		store:   store,
+		hub:     newHub(),
+		history: storage.NewHistory(cfg.HistoryRetention),
+		expiry:  storage.NewExpiry(cfg.Expiry),
+		cfg:     cfg,
+	}
+
+	srv.restoreHistory()
+
+	if cfg.Alerting.RulesPath != "" {
+		alertingCfg, err := alerting.Load(cfg.Alerting.RulesPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load alerting config: %w", err)
+		}
+		srv.alerts, err = alerting.NewEngine(alertingCfg, store, srv.history, srv.expiry, lg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create alerting engine: %w", err)
+		}
 	}
 
 	srv.routes()
@@ -68,6 +90,13 @@ func (s *Server) Start(cfg config.ServerCfg) error {
 	}
 
 	saveData(s)
+	go s.pruneHistory()
+	if s.expiry.Enabled() {
+		go s.expireMetrics()
+	}
+	if s.alerts != nil {
+		go s.alerts.Run(context.Background(), cfg.Alerting.Interval)
+	}
 
 	s.logger.Info("starting server")
 	if err := server.ListenAndServe(); err != nil {
@@ -84,27 +113,42 @@ func (s *Server) routes() {
 
 	s.router.Route("/", func(r chi.Router) {
 		r.Use(logger.Logger(lg))
-		r.Use(s.GzipMiddleware())
 
-		r.Get("/", s.IndexHandler)
-		r.Get("/ping", s.PingHandler)
+		// Сжатие буферизует ответ, поэтому поток событий идёт без него.
+		r.Get("/stream", s.StreamHandler)
 
-		r.Get("/value/{mtype}/{mname}", s.GetHandler(lg))
-		r.Post("/value/", s.GetHandler(lg))
+		r.Group(func(r chi.Router) {
+			r.Use(s.GzipMiddleware())
 
-		r.Post("/update/{mtype}/{mname}/{mvalue}", s.UpdateHandler(lg))
-		r.Post("/updates/", s.UpdatesHandler(lg))
-		r.Post("/update/", s.UpdateHandler(lg))
-	})
-}
+			r.Get("/", s.IndexHandler)
+			r.Get("/ping", s.PingHandler)
+			r.Get("/api/v1/metrics", s.ListHandler)
+			r.Get("/api/v1/alerts", s.AlertsHandler)
+			r.Get("/api/v1/rate/{name}", s.RateHandler)
+			r.Get("/api/v1/query", s.QueryHandler)
 
-func createTemplate() (*template.Template, error) {
-	tpl := `{{.}}`
-	t, err := template.New("Metrics Template").Parse(tpl)
-	if err != nil {
-		return nil, fmt.Errorf("an error occured parsing metrics template: %w", err)
-	}
-	return t, nil
+			r.Group(func(r chi.Router) {
+				r.Use(s.AdminMiddleware())
+
+				r.Delete("/api/v1/metrics/{mtype}/{mname}", s.DeleteHandler)
+				r.Post("/api/v1/metrics/{mtype}/{mname}/reset", s.ResetHandler)
+			})
+
+			r.Route("/grafana", func(r chi.Router) {
+				r.Get("/", s.GrafanaTestHandler)
+				r.Post("/search", s.GrafanaSearchHandler)
+				r.Post("/query", s.GrafanaQueryHandler)
+				r.Post("/annotations", s.GrafanaAnnotationsHandler)
+			})
+
+			r.Get("/value/{mtype}/{mname}", s.GetHandler(lg))
+			r.Post("/value/", s.GetHandler(lg))
+
+			r.Post("/update/{mtype}/{mname}/{mvalue}", s.UpdateHandler(lg))
+			r.Post("/updates/", s.UpdatesHandler(lg))
+			r.Post("/update/", s.UpdateHandler(lg))
+		})
+	})
 }
 
 func saveData(s *Server) {
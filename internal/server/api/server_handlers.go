@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -14,8 +15,6 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
-
-	"go-yandex-metrics/internal/storage"
 )
 
 const (
@@ -75,51 +74,11 @@ func (s *Server) PingHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) IndexHandler(w http.ResponseWriter, r *http.Request) {
-	requestedJSON := false
-	contentType := r.Header.Get("Content-Type")
-	if contentType == applicationJSON {
-		requestedJSON = true
-	}
-
-	contentEncoding := r.Header.Get(acceptEncoding)
-	acceptsGzip := strings.Contains(contentEncoding, gzipStr)
-
-	allMetrics, err := s.store.GetAllMetrics()
-	if err != nil {
-		s.logger.Info("an error occured getting a list of metrics:", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	t := s.tpl
-	var doc bytes.Buffer
-	err = t.Execute(&doc, allMetrics)
-	if err != nil {
-		s.logger.Info("an error occured processing template data:", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if requestedJSON {
-		w.Header().Set(contentTypeStr, applicationJSON)
-	} else {
-		w.Header().Set(contentTypeStr, "text/html")
-	}
-	w.Header().Set("charset", "utf-8")
-	w.WriteHeader(http.StatusOK)
-
-	if acceptsGzip {
-		w.Header().Set(contentEncStr, gzipStr)
-	}
-
-	html := doc.String()
-
-	_, err = w.Write([]byte(html))
-	if err != nil {
-		s.logger.Info("an error occured writing to browser:", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+	if wantsJSON(r) {
+		s.ListHandler(w, r)
 		return
 	}
+	s.DashboardHandler(w, r)
 }
 
 func (s *Server) GetHandler(lg *zap.Logger) http.HandlerFunc {
@@ -150,7 +109,7 @@ func (s *Server) GetHandler(lg *zap.Logger) http.HandlerFunc {
 				return
 			}
 
-			mValue, err := s.store.GetMetric(m.MType, m.ID)
+			mValue, err := s.lookupMetric(r, m.MType, m.ID)
 			if err != nil {
 				s.logger.Info("failed to get metric:", zap.Error(err))
 				w.WriteHeader(http.StatusNotFound)
@@ -215,32 +174,28 @@ func (s *Server) GetHandler(lg *zap.Logger) http.HandlerFunc {
 			}
 		} else {
 			mType := chi.URLParam(r, "mtype")
-			mName := chi.URLParam(r, "mname")
-			mValue, err := s.store.GetMetric(mType, mName)
+			// Имя серии с метками приходит в пути экранированным.
+			mName, err := url.PathUnescape(chi.URLParam(r, "mname"))
 			if err != nil {
-				s.logger.Info("metric not found:", zap.Error(err))
-				http.Error(w, err.Error(), http.StatusNotFound)
+				s.logger.Info("failed to unescape metric name:", zap.Error(err))
+				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
-			t := s.tpl
-			var doc bytes.Buffer
-			err = t.Execute(&doc, mValue)
+
+			mValue, err := s.lookupMetric(r, mType, mName)
 			if err != nil {
-				s.logger.Info("an error occured processing template data:", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				s.logger.Info("metric not found:", zap.Error(err))
+				http.Error(w, err.Error(), http.StatusNotFound)
 				return
 			}
-
-			w.Header().Set(contentTypeStr, "text/html")
+			w.Header().Set(contentTypeStr, "text/plain")
 			w.Header().Set("charset", "utf-8")
 
-			html := doc.String()
-
 			if acceptsGzip {
 				w.Header().Set(contentEncStr, gzipStr)
 			}
 
-			_, err = w.Write([]byte(html))
+			_, err = w.Write([]byte(mValue))
 			if err != nil {
 				s.logger.Info("an error occured writing to browser:", zap.Error(err))
 				w.WriteHeader(http.StatusInternalServerError)
@@ -278,20 +233,25 @@ func (s *Server) UpdateHandler(lg *zap.Logger) http.HandlerFunc {
 			}
 
 			mType := m.MType
-			mName := m.ID
+			mName, err := seriesID(r, m.ID)
+			if err != nil {
+				s.logger.Info("failed to get series id:", zap.Error(err))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
 			var mValueFloat string
 			var mValueInt string
 
 			switch mType {
 			case GaugeType:
 				mValueFloat = strconv.FormatFloat(*m.Value, 'f', -1, 64)
-				if err := storage.Storage.SaveMetric(s.store, mType, mName, mValueFloat); err != nil {
+				if err := s.saveMetric(mType, mName, mValueFloat); err != nil {
 					s.logger.Info("error saving gauge metric:", zap.Error(err))
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
 
-				metric := Metrics{ID: mName, MType: GaugeType, Value: m.Value}
+				metric := Metrics{ID: m.ID, MType: GaugeType, Value: m.Value}
 
 				var buf bytes.Buffer
 				err := json.NewEncoder(&buf).Encode(metric)
@@ -316,13 +276,13 @@ func (s *Server) UpdateHandler(lg *zap.Logger) http.HandlerFunc {
 				return
 			case CounterType:
 				mValueInt = strconv.FormatInt(*m.Delta, 10)
-				if err := storage.Storage.SaveMetric(s.store, mType, mName, mValueInt); err != nil {
+				if err := s.saveMetric(mType, mName, mValueInt); err != nil {
 					s.logger.Info("error saving counter metric:", zap.Error(err))
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
 
-				metric := Metrics{ID: mName, MType: CounterType, Delta: m.Delta}
+				metric := Metrics{ID: m.ID, MType: CounterType, Delta: m.Delta}
 
 				var buf bytes.Buffer
 				err := json.NewEncoder(&buf).Encode(metric)
@@ -355,13 +315,18 @@ func (s *Server) UpdateHandler(lg *zap.Logger) http.HandlerFunc {
 			fmt.Println(r.RequestURI)
 
 			mType := chi.URLParam(r, "mtype")
-			mName := chi.URLParam(r, "mname")
+			mName, err := seriesID(r, chi.URLParam(r, "mname"))
+			if err != nil {
+				s.logger.Info("failed to get series id:", zap.Error(err))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
 			mValue := chi.URLParam(r, "mvalue")
 
 			fmt.Println(mType, mName, mValue)
 
 			if mType == GaugeType || mType == CounterType {
-				if err := storage.Storage.SaveMetric(s.store, mType, mName, mValue); err != nil {
+				if err := s.saveMetric(mType, mName, mValue); err != nil {
 					s.logger.Info("error saving metric:", zap.Error(err))
 					w.WriteHeader(http.StatusBadRequest)
 					return
@@ -404,17 +369,24 @@ func (s *Server) UpdatesHandler(lg *zap.Logger) http.HandlerFunc {
 			var mValueFloat string
 			var mValueInt string
 
+			b.ID, err = seriesID(r, b.ID)
+			if err != nil {
+				s.logger.Info("failed to get series id:", zap.Error(err))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
 			switch b.MType {
 			case GaugeType:
 				mValueFloat = strconv.FormatFloat(b.Value, 'f', -1, 64)
-				if err := storage.Storage.SaveMetric(s.store, b.MType, b.ID, mValueFloat); err != nil {
+				if err := s.saveMetric(b.MType, b.ID, mValueFloat); err != nil {
 					s.logger.Info("error saving gauge metric:", zap.Error(err))
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
 			case CounterType:
 				mValueInt = strconv.FormatInt(b.Delta, 10)
-				if err := storage.Storage.SaveMetric(s.store, b.MType, b.ID, mValueInt); err != nil {
+				if err := s.saveMetric(b.MType, b.ID, mValueInt); err != nil {
 					s.logger.Info("error saving counter metric:", zap.Error(err))
 					w.WriteHeader(http.StatusInternalServerError)
 					return
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-yandex-metrics/internal/labels"
+	"go-yandex-metrics/internal/storage"
+)
+
+const (
+	agentIDHeader     = "X-Agent-ID"
+	agentLabelsHeader = "X-Agent-Labels"
+	agentLabel        = "agent"
+)
+
+// seriesID namespaces a metric by the agent that reports it, so a fleet of
+// agents can use the same metric names: HeapAlloc sent by agent host-1 with
+// labels dc=eu is stored as HeapAlloc{agent="host-1",dc="eu"}. Requests
+// without agent headers keep the plain name.
+func seriesID(r *http.Request, id string) (string, error) {
+	agentID := r.Header.Get(agentIDHeader)
+	rawLabels := r.Header.Get(agentLabelsHeader)
+	if agentID == "" && rawLabels == "" {
+		return id, nil
+	}
+
+	static := labels.Labels{}
+	if rawLabels != "" {
+		values, err := url.ParseQuery(rawLabels)
+		if err != nil {
+			return "", fmt.Errorf("malformed %s header: %w", agentLabelsHeader, err)
+		}
+		for k := range values {
+			static[k] = values.Get(k)
+		}
+	}
+
+	name, own, err := labels.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("malformed metric id: %w", err)
+	}
+
+	merged := labels.Merge(static, own)
+	if agentID != "" {
+		merged[agentLabel] = agentID
+	}
+
+	return labels.Format(name, merged), nil
+}
+
+// lookupMetric finds the value a /value/ request asks for. The exact ID comes
+// first, then the series the agent headers namespace it to. A plain name that
+// is not stored by itself falls back to the labelled series of that name, so
+// lookups keep working once agents label their metrics: counters add up
+// across the series and gauges take the latest value.
+func (s *Server) lookupMetric(r *http.Request, mType, id string) (string, error) {
+	ids := []string{id}
+	if sid, err := seriesID(r, id); err == nil && sid != id {
+		ids = append(ids, sid)
+	}
+	for _, id := range ids {
+		mValue, err := s.store.GetMetric(mType, id)
+		if errors.Is(err, storage.ErrMetricNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get metric: %w", err)
+		}
+		return mValue, nil
+	}
+
+	notFound := fmt.Errorf("%s %w", mType, storage.ErrMetricNotFound)
+	if strings.ContainsRune(id, '{') {
+		return "", notFound
+	}
+	stored, err := s.liveMetrics()
+	if err != nil {
+		return "", err
+	}
+
+	var latest *storage.Metric
+	var total int64
+	for i, m := range stored {
+		if m.MType != mType {
+			continue
+		}
+		if name, _, err := labels.Parse(m.ID); err != nil || name != id {
+			continue
+		}
+		total += m.Delta
+		if latest == nil || m.UpdatedAt.After(latest.UpdatedAt) {
+			latest = &stored[i]
+		}
+	}
+	if latest == nil {
+		return "", notFound
+	}
+	if mType == CounterType {
+		return strconv.FormatInt(total, 10), nil
+	}
+	return formatValue(*latest), nil
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestSeriesID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		agentID string
+		labels  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no agent headers keep the plain name",
+			id:   "Alloc",
+			want: "Alloc",
+		},
+		{
+			name:    "agent ID becomes a label",
+			id:      "Alloc",
+			agentID: "host-1",
+			want:    `Alloc{agent="host-1"}`,
+		},
+		{
+			name:   "static labels without an agent ID",
+			id:     "Alloc",
+			labels: "dc=eu&rack=r1",
+			want:   `Alloc{dc="eu",rack="r1"}`,
+		},
+		{
+			name:    "labels of the metric override static ones, agent overrides both",
+			id:      `Alloc{dc="us",agent="spoofed"}`,
+			agentID: "host-1",
+			labels:  "dc=eu",
+			want:    `Alloc{agent="host-1",dc="us"}`,
+		},
+		{
+			name:    "malformed labels header",
+			id:      "Alloc",
+			labels:  "dc=%zz",
+			wantErr: true,
+		},
+		{
+			name:    "malformed metric id",
+			id:      `Alloc{dc=`,
+			agentID: "host-1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/update/", http.NoBody)
+			if tt.agentID != "" {
+				r.Header.Set(agentIDHeader, tt.agentID)
+			}
+			if tt.labels != "" {
+				r.Header.Set(agentLabelsHeader, tt.labels)
+			}
+
+			got, err := seriesID(r, tt.id)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestServer_ValueLookup(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, store)
+
+	for _, w := range []struct {
+		agentID string
+		body    string
+	}{
+		{"", `{"id":"Alloc","type":"gauge","value":1}`},
+		{"host-1", `{"id":"Alloc","type":"gauge","value":2}`},
+		{"host-1", `{"id":"Sys","type":"gauge","value":10}`},
+		{"host-2", `{"id":"Sys","type":"gauge","value":20}`},
+		{"host-1", `{"id":"PollCount","type":"counter","delta":3}`},
+		{"host-2", `{"id":"PollCount","type":"counter","delta":4}`},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(w.body))
+		r.Header.Set(contentTypeStr, applicationJSON)
+		if w.agentID != "" {
+			r.Header.Set(agentIDHeader, w.agentID)
+		}
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, r)
+		assert.Equal(t, rr.Code, http.StatusOK)
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		agentID  string
+		wantBody string
+		wantCode int
+	}{
+		{
+			name:     "plain name",
+			url:      "/value/gauge/Alloc",
+			wantBody: "1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "stored plain name wins over the agent's series",
+			url:      "/value/gauge/Alloc",
+			agentID:  "host-1",
+			wantBody: "1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "labelled series by its escaped ID",
+			url:      "/value/gauge/Alloc%7Bagent=%22host-1%22%7D",
+			wantBody: "2",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "agent headers pick the agent's series",
+			url:      "/value/gauge/Sys",
+			agentID:  "host-1",
+			wantBody: "10",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "plain name of labelled gauges gives the latest value",
+			url:      "/value/gauge/Sys",
+			wantBody: "20",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "plain name of labelled counters gives the sum",
+			url:      "/value/counter/PollCount",
+			wantBody: "7",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "unknown series",
+			url:      "/value/gauge/HeapAlloc",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "unknown labelled series",
+			url:      "/value/gauge/Sys%7Bagent=%22host-3%22%7D",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, http.NoBody)
+			if tt.agentID != "" {
+				r.Header.Set(agentIDHeader, tt.agentID)
+			}
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.Equal(t, rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	streamBuffer    = 256
+	streamKeepAlive = 15 * time.Second
+)
+
+type subscriber struct {
+	events  chan Metrics
+	filter  func(Metrics) bool
+	dropped *atomic.Int64
+}
+
+// hub fans saved metrics out to /stream subscribers. Publishing never blocks:
+// a subscriber that does not keep up loses events and is told how many.
+type hub struct {
+	mu          *sync.RWMutex
+	subscribers map[*subscriber]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		mu:          &sync.RWMutex{},
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (h *hub) subscribe(filter func(Metrics) bool) *subscriber {
+	sub := &subscriber{
+		events:  make(chan Metrics, streamBuffer),
+		filter:  filter,
+		dropped: &atomic.Int64{},
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+func (h *hub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	delete(h.subscribers, sub)
+	h.mu.Unlock()
+}
+
+func (h *hub) active() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers) > 0
+}
+
+func (h *hub) publish(m Metrics) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers {
+		if !sub.filter(m) {
+			continue
+		}
+		select {
+		case sub.events <- m:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// saveMetric stores a metric, records its new value in the history and
+// publishes it to /stream. Every ingest handler goes through it.
+func (s *Server) saveMetric(mType, mName, mValue string) error {
+	// Для счётчика в историю и поток идёт накопленная сумма, а не пришедшая
+	// дельта, причём ровно та, что получилась при этой записи.
+	m, err := s.store.SaveMetric(mType, mName, mValue)
+	if err != nil {
+		return fmt.Errorf("failed to save metric: %w", err)
+	}
+
+	s.history.Record(m.MType, m.ID, m.Float64(), time.Now())
+	if s.hub.active() {
+		s.hub.publish(toMetrics(m))
+	}
+
+	return nil
+}
+
+// StreamHandler sends every saved metric as a Server-Sent Event with the
+// same JSON as /api/v1/metrics. type and name (a glob such as Heap*) narrow
+// the stream down. When events are lost a "dropped" event carries how many,
+// so the client can reload the full listing.
+func (s *Server) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	mType := r.URL.Query().Get("type")
+	if mType != "" && mType != GaugeType && mType != CounterType {
+		http.Error(w, fmt.Sprintf("unknown type %q", mType), http.StatusBadRequest)
+		return
+	}
+	pattern := r.URL.Query().Get("name")
+	if _, err := path.Match(pattern, ""); err != nil {
+		http.Error(w, fmt.Sprintf("bad name pattern %q: %v", pattern, err), http.StatusBadRequest)
+		return
+	}
+
+	sub := s.hub.subscribe(func(m Metrics) bool {
+		if mType != "" && m.MType != mType {
+			return false
+		}
+		if pattern == "" {
+			return true
+		}
+		ok, _ := path.Match(pattern, m.ID)
+		return ok
+	})
+	defer s.hub.unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set(contentTypeStr, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		s.logger.Info("streaming is not supported by the connection:", zap.Error(err))
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case m := <-sub.events:
+			if dropped := sub.dropped.Swap(0); dropped > 0 {
+				_, err = fmt.Fprintf(w, "event: dropped\ndata: %d\n\n", dropped)
+			}
+			if err == nil {
+				err = writeEvent(w, m)
+			}
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			s.logger.Info("failed to write to the stream:", zap.Error(err))
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, m Metrics) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to JSON encode metric: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/assert"
+	"go.uber.org/zap"
+
+	"go-yandex-metrics/internal/config"
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestServer_StreamHandler(t *testing.T) {
+	store, err := storage.NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, store)
+
+	srv := httptest.NewServer(s.router)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream?name=Poll*", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, resp.Header.Get(contentTypeStr), "text/event-stream")
+
+	for _, url := range []string{
+		"/update/gauge/HeapAlloc/1",
+		"/update/counter/PollCount/2",
+		"/update/counter/PollCount/3",
+	} {
+		post, err := http.Post(srv.URL+url, "text/plain", http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		post.Body.Close()
+	}
+
+	var got []int64
+	scanner := bufio.NewScanner(resp.Body)
+	for len(got) < 2 && scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var m Metrics
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, m.ID, "PollCount")
+		got = append(got, *m.Delta)
+	}
+	assert.Equal(t, got, []int64{2, 5})
+}
+
+func TestHub_SlowSubscriber(t *testing.T) {
+	h := newHub()
+	sub := h.subscribe(func(Metrics) bool { return true })
+
+	for range streamBuffer + 3 {
+		h.publish(Metrics{ID: "PollCount", MType: CounterType})
+	}
+
+	assert.Equal(t, len(sub.events), streamBuffer)
+	assert.Equal(t, sub.dropped.Load(), int64(3))
+
+	h.unsubscribe(sub)
+	assert.Equal(t, h.active(), false)
+}
+
+// newTestServer wires a server with all its routes around the store, with an
+// empty history and no expiry or alerting.
+func newTestServer(t *testing.T, store storage.Storage) *Server {
+	t.Helper()
+
+	s := &Server{
+		router:  chi.NewRouter(),
+		store:   store,
+		hub:     newHub(),
+		history: storage.NewHistory(time.Hour),
+		logger:  zap.NewNop(),
+	}
+	s.routes()
+	return s
+}
@@ -0,0 +1,345 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go-yandex-metrics/internal/labels"
+	"go-yandex-metrics/internal/storage"
+)
+
+const (
+	KindScalar = "scalar"
+	KindVector = "vector"
+	KindMatrix = "matrix"
+)
+
+// Source is where queries read samples from; storage.History implements it.
+type Source interface {
+	Series(mType string) []string
+	Range(mType, id string, from, to time.Time) []storage.Sample
+}
+
+// Series is one series of a result. Name is only set while the values are
+// those of the stored series, before functions, aggregation or arithmetic.
+// Vectors carry Value, matrices carry Samples.
+type Series struct {
+	Labels  labels.Labels
+	Name    string
+	Samples []storage.Sample
+	Value   float64
+}
+
+// ID formats the series the way it is stored.
+func (s Series) ID() string {
+	return labels.Format(s.Name, s.Labels)
+}
+
+// Value is the result of a query: a number, a vector of values at the query
+// time, or a matrix of samples within a range.
+type Value struct {
+	Kind   string
+	Series []Series
+	Scalar float64
+}
+
+var errEval = errors.New("cannot evaluate query")
+
+// Eval runs a query as of at. Vector and matrix series are sorted by ID and
+// series whose values are not finite numbers, e.g. after a division by zero,
+// are left out.
+func Eval(src Source, q string, at time.Time) (Value, error) {
+	n, err := parse(q)
+	if err != nil {
+		return Value{}, err
+	}
+
+	v, err := eval(src, n, at)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if v.Kind == KindScalar {
+		if math.IsNaN(v.Scalar) || math.IsInf(v.Scalar, 0) {
+			return Value{}, fmt.Errorf("%w: result %v is not a finite number", errEval, v.Scalar)
+		}
+		return v, nil
+	}
+
+	finite := v.Series[:0]
+	for _, s := range v.Series {
+		if !math.IsNaN(s.Value) && !math.IsInf(s.Value, 0) {
+			finite = append(finite, s)
+		}
+	}
+	v.Series = finite
+	sort.Slice(v.Series, func(i, j int) bool { return v.Series[i].ID() < v.Series[j].ID() })
+	return v, nil
+}
+
+func eval(src Source, n node, at time.Time) (Value, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return Value{Kind: KindScalar, Scalar: n.value}, nil
+	case *selectorNode:
+		return evalSelector(src, n, at), nil
+	case *callNode:
+		return evalCall(src, n, at), nil
+	case *aggrNode:
+		return evalAggr(src, n, at)
+	case *binaryNode:
+		return evalBinary(src, n, at)
+	}
+	return Value{}, fmt.Errorf("%w: unknown node %T", errEval, n)
+}
+
+type selected struct {
+	mType string
+	id    string
+	name  string
+	lbls  labels.Labels
+}
+
+func selectSeries(src Source, sel *selectorNode) []selected {
+	var found []selected
+	for _, mType := range []string{storage.GaugeType, storage.CounterType} {
+		for _, id := range src.Series(mType) {
+			name, lbls, err := labels.Parse(id)
+			if err != nil || name != sel.name || !matchLabels(lbls, sel.matchers) {
+				continue
+			}
+			found = append(found, selected{mType: mType, id: id, name: name, lbls: lbls})
+		}
+	}
+	return found
+}
+
+func matchLabels(lbls labels.Labels, matchers []matcher) bool {
+	for _, m := range matchers {
+		if (lbls[m.label] == m.value) == m.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// evalSelector returns the last value of every series as of at or, for a
+// range selector, the samples taken within the window.
+func evalSelector(src Source, sel *selectorNode, at time.Time) Value {
+	if sel.window == 0 {
+		v := Value{Kind: KindVector}
+		for _, s := range selectSeries(src, sel) {
+			samples := src.Range(s.mType, s.id, at, at)
+			if len(samples) == 0 {
+				continue
+			}
+			v.Series = append(v.Series, Series{Name: s.name, Labels: s.lbls, Value: samples[len(samples)-1].Value})
+		}
+		return v
+	}
+
+	from := at.Add(-sel.window)
+	v := Value{Kind: KindMatrix}
+	for _, s := range selectSeries(src, sel) {
+		samples := src.Range(s.mType, s.id, from, at)
+		for len(samples) > 0 && samples[0].At.Before(from) {
+			samples = samples[1:]
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		v.Series = append(v.Series, Series{Name: s.name, Labels: s.lbls, Samples: samples})
+	}
+	return v
+}
+
+func evalCall(src Source, n *callNode, at time.Time) Value {
+	from := at.Add(-n.arg.window)
+	v := Value{Kind: KindVector}
+	for _, s := range selectSeries(src, n.arg) {
+		rate, ok := storage.ComputeRate(src.Range(s.mType, s.id, from, at), from, at, s.mType == storage.CounterType)
+		if !ok {
+			continue
+		}
+		value := rate.Rate
+		if n.fn == "increase" {
+			value = rate.Increase
+		}
+		v.Series = append(v.Series, Series{Labels: s.lbls, Value: value})
+	}
+	return v
+}
+
+func evalAggr(src Source, n *aggrNode, at time.Time) (Value, error) {
+	arg, err := eval(src, n.arg, at)
+	if err != nil {
+		return Value{}, err
+	}
+	if arg.Kind != KindVector {
+		return Value{}, fmt.Errorf("%w: %s needs a vector, got a %s", errEval, n.op, arg.Kind)
+	}
+
+	type group struct {
+		lbls   labels.Labels
+		values []float64
+	}
+	groups := make(map[string]*group)
+	for _, s := range arg.Series {
+		lbls := labels.Labels{}
+		for _, l := range n.by {
+			if value, ok := s.Labels[l]; ok {
+				lbls[l] = value
+			}
+		}
+		key := labels.Format("", lbls)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{lbls: lbls}
+			groups[key] = g
+		}
+		g.values = append(g.values, s.Value)
+	}
+
+	v := Value{Kind: KindVector}
+	for _, g := range groups {
+		v.Series = append(v.Series, Series{Labels: g.lbls, Value: aggregate(n.op, g.values)})
+	}
+	return v, nil
+}
+
+func aggregate(op string, values []float64) float64 {
+	result := values[0]
+	switch op {
+	case "sum", "avg":
+		for _, value := range values[1:] {
+			result += value
+		}
+		if op == "avg" {
+			result /= float64(len(values))
+		}
+	case "max":
+		for _, value := range values[1:] {
+			result = math.Max(result, value)
+		}
+	case "min":
+		for _, value := range values[1:] {
+			result = math.Min(result, value)
+		}
+	default:
+		result = float64(len(values))
+	}
+	return result
+}
+
+// evalBinary applies arithmetic to two numbers, to every series of a vector
+// and a number, or to the series of two vectors that have the same labels.
+func evalBinary(src Source, n *binaryNode, at time.Time) (Value, error) {
+	lhs, err := eval(src, n.lhs, at)
+	if err != nil {
+		return Value{}, err
+	}
+	rhs, err := eval(src, n.rhs, at)
+	if err != nil {
+		return Value{}, err
+	}
+	if lhs.Kind == KindMatrix || rhs.Kind == KindMatrix {
+		return Value{}, fmt.Errorf("%w: %s does not apply to range selectors, use rate or increase", errEval, n.op)
+	}
+
+	switch {
+	case lhs.Kind == KindScalar && rhs.Kind == KindScalar:
+		return Value{Kind: KindScalar, Scalar: apply(n.op, lhs.Scalar, rhs.Scalar)}, nil
+	case rhs.Kind == KindScalar:
+		v := Value{Kind: KindVector}
+		for _, s := range lhs.Series {
+			v.Series = append(v.Series, Series{Labels: s.Labels, Value: apply(n.op, s.Value, rhs.Scalar)})
+		}
+		return v, nil
+	case lhs.Kind == KindScalar:
+		v := Value{Kind: KindVector}
+		for _, s := range rhs.Series {
+			v.Series = append(v.Series, Series{Labels: s.Labels, Value: apply(n.op, lhs.Scalar, s.Value)})
+		}
+		return v, nil
+	}
+
+	byLabels := make(map[string]Series, len(rhs.Series))
+	for _, s := range rhs.Series {
+		key := labels.Format("", s.Labels)
+		if _, ok := byLabels[key]; ok {
+			return Value{}, fmt.Errorf("%w: several series on the right of %s have the same labels, aggregate them first",
+				errEval, n.op)
+		}
+		byLabels[key] = s
+	}
+	v := Value{Kind: KindVector}
+	for _, s := range lhs.Series {
+		if other, ok := byLabels[labels.Format("", s.Labels)]; ok {
+			v.Series = append(v.Series, Series{Labels: s.Labels, Value: apply(n.op, s.Value, other.Value)})
+		}
+	}
+	return v, nil
+}
+
+func apply(op string, a, b float64) float64 {
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	default:
+		return a / b
+	}
+}
+
+// EvalRange runs a query at every step from start to end and returns a
+// series of samples for every series found at any step. Scalar results come
+// back as a single series without labels.
+func EvalRange(src Source, q string, start, end time.Time, step time.Duration) ([]Series, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("%w: step must be positive", errEval)
+	}
+	n, err := parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*Series)
+	for at := start; !at.After(end); at = at.Add(step) {
+		v, err := eval(src, n, at)
+		if err != nil {
+			return nil, err
+		}
+		switch v.Kind {
+		case KindMatrix:
+			return nil, fmt.Errorf("%w: range selectors need rate or increase in range queries", errEval)
+		case KindScalar:
+			v.Series = []Series{{Labels: labels.Labels{}, Value: v.Scalar}}
+		}
+
+		for _, s := range v.Series {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			id := s.ID()
+			series, ok := byID[id]
+			if !ok {
+				series = &Series{Name: s.Name, Labels: s.Labels}
+				byID[id] = series
+			}
+			series.Samples = append(series.Samples, storage.Sample{At: at, Value: s.Value})
+		}
+	}
+
+	result := make([]Series, 0, len(byID))
+	for _, s := range byID {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID() < result[j].ID() })
+	return result, nil
+}
@@ -0,0 +1,374 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokRange
+	tokOp
+)
+
+type token struct {
+	text string
+	kind tokenKind
+	pos  int
+}
+
+// lex splits a query into identifiers, numbers, quoted strings, range
+// windows such as [5m] and operators.
+func lex(q string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(q); {
+		c := rune(q[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(q[i:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			value, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, fmt.Errorf("bad string at %d: %w", i, err)
+			}
+			tokens = append(tokens, token{kind: tokString, text: value, pos: i})
+			i += len(quoted)
+		case c == '[':
+			end := strings.IndexByte(q[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated range at %d", i)
+			}
+			tokens = append(tokens, token{kind: tokRange, text: strings.TrimSpace(q[i+1 : i+end]), pos: i})
+			i += end + 1
+		case c == '!' && strings.HasPrefix(q[i:], "!="):
+			tokens = append(tokens, token{kind: tokOp, text: "!=", pos: i})
+			i += 2
+		case strings.ContainsRune("+-*/(){},=", c):
+			tokens = append(tokens, token{kind: tokOp, text: string(c), pos: i})
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(q) && (isNumberChar(q[j]) || (q[j] == '+' || q[j] == '-') && (q[j-1] == 'e' || q[j-1] == 'E')) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: q[i:j], pos: i})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(q) && isIdentChar(rune(q[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: q[i:j], pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(q)}), nil
+}
+
+func isNumberChar(c byte) bool {
+	return c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || c < unicode.MaxASCII && unicode.IsLetter(c)
+}
+
+func isIdentChar(c rune) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '.' || c == ':'
+}
+
+type node interface{}
+
+type numberNode struct {
+	value float64
+}
+
+type matcher struct {
+	label  string
+	value  string
+	negate bool
+}
+
+type selectorNode struct {
+	name     string
+	matchers []matcher
+	window   time.Duration
+}
+
+type callNode struct {
+	arg *selectorNode
+	fn  string
+}
+
+type aggrNode struct {
+	arg node
+	op  string
+	by  []string
+}
+
+type binaryNode struct {
+	lhs node
+	rhs node
+	op  string
+}
+
+var (
+	functions    = map[string]bool{"rate": true, "increase": true}
+	aggregations = map[string]bool{"sum": true, "avg": true, "max": true, "min": true, "count": true}
+)
+
+var errSyntax = errors.New("syntax error")
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse builds the syntax tree of a query:
+//
+//	expr     = term { ("+" | "-") term }
+//	term     = unary { ("*" | "/") unary }
+//	unary    = "-" unary | primary
+//	primary  = number | "(" expr ")" | call | aggr | selector
+//	call     = ("rate" | "increase") "(" selector ")"
+//	aggr     = op [ "by" labels ] "(" expr ")" [ "by" labels ]
+//	selector = name [ "{" label ("=" | "!=") string { "," ... } "}" ] [ "[" duration "]" ]
+func parse(q string) (node, error) {
+	tokens, err := lex(q)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errSyntax, err)
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) isOp(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokOp && tok.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if tok := p.next(); tok.kind != tokOp || tok.text != text {
+		return p.errorf(tok, "expected %q", text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("%w at %d: %s", errSyntax, tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expr() (node, error) {
+	lhs, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text
+		rhs, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binaryNode{op: op, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) term() (node, error) {
+	lhs, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") {
+		op := p.next().text
+		rhs, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binaryNode{op: op, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.isOp("-") {
+		p.next()
+		arg, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: "*", lhs: &numberNode{value: -1}, rhs: arg}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokNumber:
+		p.next()
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "bad number %q", tok.text)
+		}
+		return &numberNode{value: value}, nil
+	case p.isOp("("):
+		p.next()
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tok.kind == tokIdent && functions[tok.text] && p.tokens[p.pos+1].text == "(":
+		p.next()
+		p.next()
+		sel, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		if sel.window == 0 {
+			return nil, p.errorf(tok, "%s needs a range selector such as %s[5m]", tok.text, sel.name)
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &callNode{fn: tok.text, arg: sel}, nil
+	case tok.kind == tokIdent && aggregations[tok.text] &&
+		(p.tokens[p.pos+1].text == "(" || p.tokens[p.pos+1].text == "by"):
+		return p.aggr()
+	case tok.kind == tokIdent:
+		return p.selector()
+	case tok.kind == tokEOF:
+		return nil, p.errorf(tok, "unexpected end of query")
+	}
+	return nil, p.errorf(tok, "unexpected %q", tok.text)
+}
+
+func (p *parser) aggr() (node, error) {
+	n := &aggrNode{op: p.next().text}
+
+	var err error
+	if p.peek().text == "by" {
+		if n.by, err = p.byLabels(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if n.arg, err = p.expr(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if n.by == nil && p.peek().kind == tokIdent && p.peek().text == "by" {
+		if n.by, err = p.byLabels(); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) byLabels() ([]string, error) {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	by := make([]string, 0)
+	for !p.isOp(")") {
+		tok := p.next()
+		if tok.kind != tokIdent {
+			return nil, p.errorf(tok, "expected a label name")
+		}
+		by = append(by, tok.text)
+		if !p.isOp(")") {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p.next()
+	return by, nil
+}
+
+func (p *parser) selector() (*selectorNode, error) {
+	tok := p.next()
+	if tok.kind != tokIdent {
+		return nil, p.errorf(tok, "expected a metric name")
+	}
+	sel := &selectorNode{name: tok.text}
+
+	if p.isOp("{") {
+		p.next()
+		for !p.isOp("}") {
+			label := p.next()
+			if label.kind != tokIdent {
+				return nil, p.errorf(label, "expected a label name")
+			}
+			op := p.next()
+			if op.kind != tokOp || (op.text != "=" && op.text != "!=") {
+				return nil, p.errorf(op, "expected = or !=")
+			}
+			value := p.next()
+			if value.kind != tokString {
+				return nil, p.errorf(value, "expected a quoted label value")
+			}
+			sel.matchers = append(sel.matchers, matcher{label: label.text, value: value.text, negate: op.text == "!="})
+			if !p.isOp("}") {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+		p.next()
+	}
+
+	if tok := p.peek(); tok.kind == tokRange {
+		p.next()
+		window, err := time.ParseDuration(tok.text)
+		if err != nil || window <= 0 {
+			return nil, p.errorf(tok, "bad range %q", tok.text)
+		}
+		sel.window = window
+	}
+	return sel, nil
+}
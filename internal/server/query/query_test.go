@@ -0,0 +1,160 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/storage"
+)
+
+func TestEval(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	history := storage.NewHistory(time.Hour)
+	for i := range 5 {
+		at := now.Add(time.Duration(i-4) * time.Minute)
+		history.Record(storage.CounterType, `PollCount{agent="a1",dc="eu"}`, float64(60*i), at)
+		history.Record(storage.CounterType, `PollCount{agent="a2",dc="eu"}`, float64(120*i), at)
+		history.Record(storage.CounterType, `PollCount{agent="a3",dc="us"}`, float64(30*i), at)
+		history.Record(storage.GaugeType, `HeapAlloc{agent="a1",dc="eu"}`, float64(1000*(i+1)), at)
+		history.Record(storage.GaugeType, `HeapAlloc{agent="a2",dc="eu"}`, 2000, at)
+	}
+
+	tests := []struct {
+		name    string
+		q       string
+		want    map[string]float64
+		kind    string
+		scalar  float64
+		wantErr bool
+	}{
+		{
+			name: "selector with matchers",
+			q:    `HeapAlloc{dc="eu",agent!="a2"}`,
+			kind: KindVector,
+			want: map[string]float64{`HeapAlloc{agent="a1",dc="eu"}`: 5000},
+		},
+		{
+			name: "rate",
+			q:    `rate(PollCount{dc="eu"}[2m])`,
+			kind: KindVector,
+			want: map[string]float64{`{agent="a1",dc="eu"}`: 1, `{agent="a2",dc="eu"}`: 2},
+		},
+		{
+			name: "sum by label",
+			q:    `sum by (dc) (increase(PollCount[4m]))`,
+			kind: KindVector,
+			want: map[string]float64{`{dc="eu"}`: 720, `{dc="us"}`: 120},
+		},
+		{
+			name: "by after the arguments",
+			q:    `max(HeapAlloc) by (dc)`,
+			kind: KindVector,
+			want: map[string]float64{`{dc="eu"}`: 5000},
+		},
+		{
+			name: "arithmetic between series",
+			q:    `HeapAlloc / (PollCount + 60) * -1`,
+			kind: KindVector,
+			want: map[string]float64{`{agent="a1",dc="eu"}`: -5000.0 / 300, `{agent="a2",dc="eu"}`: -2000.0 / 540},
+		},
+		{
+			name: "avg and count",
+			q:    `avg(HeapAlloc) + count(PollCount)`,
+			kind: KindVector,
+			want: map[string]float64{``: 3503},
+		},
+		{
+			name:   "scalar",
+			q:      `2 * (3 + 1e1) / 4`,
+			kind:   KindScalar,
+			scalar: 6.5,
+		},
+		{
+			name: "range window",
+			q:    `HeapAlloc{agent="a1"}[90s]`,
+			kind: KindMatrix,
+			want: map[string]float64{`HeapAlloc{agent="a1",dc="eu"}`: 2},
+		},
+		{
+			name: "division by zero drops the series",
+			q:    `HeapAlloc / (PollCount - PollCount)`,
+			kind: KindVector,
+			want: map[string]float64{},
+		},
+		{
+			name:    "rate needs a range",
+			q:       `rate(PollCount)`,
+			wantErr: true,
+		},
+		{
+			name:    "arithmetic on a range",
+			q:       `PollCount[5m] * 2`,
+			wantErr: true,
+		},
+		{
+			name:    "unbalanced parentheses",
+			q:       `sum(rate(PollCount[5m])`,
+			wantErr: true,
+		},
+		{
+			name:    "unquoted label value",
+			q:       `PollCount{agent=a1}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Eval(history, tt.q, now)
+			assert.Equal(t, err != nil, tt.wantErr)
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, v.Kind, tt.kind)
+			if v.Kind == KindScalar {
+				assert.Equal(t, v.Scalar, tt.scalar)
+				return
+			}
+
+			got := make(map[string]float64)
+			for _, s := range v.Series {
+				got[s.ID()] = s.Value
+				if v.Kind == KindMatrix {
+					got[s.ID()] = float64(len(s.Samples))
+				}
+			}
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestEvalRange(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	history := storage.NewHistory(time.Hour)
+	history.Record(storage.CounterType, `PollCount{agent="a1"}`, 0, start)
+	history.Record(storage.CounterType, `PollCount{agent="a1"}`, 60, start.Add(time.Minute))
+	history.Record(storage.CounterType, `PollCount{agent="a2"}`, 0, start.Add(time.Minute))
+	history.Record(storage.CounterType, `PollCount{agent="a2"}`, 120, start.Add(2*time.Minute))
+
+	got, err := EvalRange(history, `rate(PollCount[1m])`, start, start.Add(2*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, got, []Series{
+		{
+			Labels: map[string]string{"agent": "a1"},
+			Samples: []storage.Sample{
+				{At: start.Add(time.Minute), Value: 1},
+				{At: start.Add(2 * time.Minute), Value: 0},
+			},
+		},
+		{
+			Labels:  map[string]string{"agent": "a2"},
+			Samples: []storage.Sample{{At: start.Add(2 * time.Minute), Value: 2}},
+		},
+	})
+
+	_, err = EvalRange(history, `PollCount[5m]`, start, start.Add(time.Minute), time.Minute)
+	assert.NotEqual(t, err, nil)
+}
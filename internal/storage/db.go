@@ -13,6 +13,7 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -20,11 +21,6 @@ type DBStorage struct {
 	pool *pgxpool.Pool
 }
 
-type Metric struct {
-	metricName  string
-	metricValue string
-}
-
 //go:embed migrations/*.sql
 var migrationsDir embed.FS
 
@@ -62,25 +58,32 @@ func runMigrations(dsn string) error {
 	return nil
 }
 
-func (d *DBStorage) SaveMetric(mType, mName, mValue string) error {
-	sqlInsert := ""
+func (d *DBStorage) SaveMetric(mType, mName, mValue string) (Metric, error) {
+	metric := Metric{MType: mType, ID: mName}
+	ctx := context.Background()
 
 	switch mType {
 	case CounterType:
-		sqlInsert = "INSERT INTO countermetrics (metricName, metricValue) VALUES ($1, $2)" +
-			"ON CONFLICT (metricName) DO UPDATE SET metricValue = countermetrics.metricValue + $3"
+		sqlInsert := "INSERT INTO countermetrics (metricName, metricValue) VALUES ($1, $2)" +
+			"ON CONFLICT (metricName) DO UPDATE SET metricValue = countermetrics.metricValue + $3, updatedat = now()" +
+			" RETURNING metricValue, updatedat"
+		err := d.pool.QueryRow(ctx, sqlInsert, mName, mValue, mValue).Scan(&metric.Delta, &metric.UpdatedAt)
+		if err != nil {
+			return metric, fmt.Errorf("cannot execute query while saving metric: %w", err)
+		}
 	case GaugeType:
-		sqlInsert = "INSERT INTO gaugemetrics (metricName, metricValue) VALUES ($1, $2)" +
-			"ON CONFLICT (metricName) DO UPDATE SET metricValue = $3"
-	}
-
-	ctx := context.Background()
-	_, err := d.pool.Exec(ctx, sqlInsert, mName, mValue, mValue)
-	if err != nil {
-		return fmt.Errorf("cannot execute query while saving metric: %w", err)
+		sqlInsert := "INSERT INTO gaugemetrics (metricName, metricValue) VALUES ($1, $2)" +
+			"ON CONFLICT (metricName) DO UPDATE SET metricValue = $3, updatedat = now()" +
+			" RETURNING metricValue, updatedat"
+		err := d.pool.QueryRow(ctx, sqlInsert, mName, mValue, mValue).Scan(&metric.Value, &metric.UpdatedAt)
+		if err != nil {
+			return metric, fmt.Errorf("cannot execute query while saving metric: %w", err)
+		}
+	default:
+		return metric, fmt.Errorf("wrong metric type: %v", mType)
 	}
 
-	return nil
+	return metric, nil
 }
 
 func (d *DBStorage) GetMetric(mType, mName string) (string, error) {
@@ -99,6 +102,9 @@ func (d *DBStorage) GetMetric(mType, mName string) (string, error) {
 	if mType == GaugeType {
 		var metricValue float64
 		err := row.Scan(&metricValue)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%s %w", GaugeType, ErrMetricNotFound)
+		}
 		if err != nil {
 			return "", fmt.Errorf("cannot get gauge metric: %w", err)
 		}
@@ -106,6 +112,9 @@ func (d *DBStorage) GetMetric(mType, mName string) (string, error) {
 	} else {
 		var metricValue int64
 		err := row.Scan(&metricValue)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%s %w", CounterType, ErrMetricNotFound)
+		}
 		if err != nil {
 			return "", fmt.Errorf("cannot get counter metric: %w", err)
 		}
@@ -113,69 +122,114 @@ func (d *DBStorage) GetMetric(mType, mName string) (string, error) {
 	}
 }
 
-func (d *DBStorage) GetAllMetrics() (string, error) {
-	html := "<h3>Gauge:</h3>"
-	gaugeMetrics, err := d.getMetrics(GaugeType)
+func (d *DBStorage) ListMetrics() ([]Metric, error) {
+	ctx := context.Background()
+	metrics := make([]Metric, 0)
+
+	gauges, err := d.pool.Query(ctx, "SELECT metricName, metricValue, updatedat FROM gaugemetrics")
 	if err != nil {
-		return "", fmt.Errorf("error getting gauge metrics: %w", err)
+		return nil, fmt.Errorf("error running sql query: %w", err)
+	}
+	for gauges.Next() {
+		m := Metric{MType: GaugeType}
+		if err := gauges.Scan(&m.ID, &m.Value, &m.UpdatedAt); err != nil {
+			gauges.Close()
+			return nil, fmt.Errorf("cannot get gauge metric: %w", err)
+		}
+		metrics = append(metrics, m)
+	}
+	if err := gauges.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching rows from the db: %w", err)
 	}
-	html += gaugeMetrics
 
-	html += "<h3>Counter:</h3>"
-	counterMetrics, err := d.getMetrics(CounterType)
+	counters, err := d.pool.Query(ctx, "SELECT metricName, metricValue, updatedat FROM countermetrics")
 	if err != nil {
-		return "", fmt.Errorf("error getting counter metrics: %w", err)
+		return nil, fmt.Errorf("error running sql query: %w", err)
+	}
+	for counters.Next() {
+		m := Metric{MType: CounterType}
+		if err := counters.Scan(&m.ID, &m.Delta, &m.UpdatedAt); err != nil {
+			counters.Close()
+			return nil, fmt.Errorf("cannot get counter metric: %w", err)
+		}
+		metrics = append(metrics, m)
+	}
+	if err := counters.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching rows from the db: %w", err)
 	}
-	html += counterMetrics
 
-	return html, nil
+	return metrics, nil
 }
 
-func (d *DBStorage) getMetrics(mType string) (string, error) {
-	sqlSelect := ""
+func (d *DBStorage) DeleteMetric(mType, mName string) error {
+	return d.deleteMetric(mType, "metricName=$1", mName)
+}
+
+func (d *DBStorage) DeleteStaleMetric(mType, mName string, before time.Time) error {
+	return d.deleteMetric(mType, "metricName=$1 AND updatedat < $2", mName, before)
+}
+
+// deleteMetric removes the series of the type matching where.
+func (d *DBStorage) deleteMetric(mType, where string, args ...any) error {
+	sqlDelete := ""
 
 	switch mType {
 	case CounterType:
-		sqlSelect = "SELECT metricName, metricValue FROM countermetrics"
+		sqlDelete = "DELETE FROM countermetrics WHERE " + where
 	case GaugeType:
-		sqlSelect = "SELECT metricName, metricValue FROM gaugemetrics"
+		sqlDelete = "DELETE FROM gaugemetrics WHERE " + where
+	default:
+		return fmt.Errorf("wrong metric type: %v", mType)
 	}
 
 	ctx := context.Background()
-	rows, err := d.pool.Query(ctx, sqlSelect)
+	tag, err := d.pool.Exec(ctx, sqlDelete, args...)
 	if err != nil {
-		return "", fmt.Errorf("error running sql query: %w", err)
+		return fmt.Errorf("cannot execute query while deleting metric: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s %w", mType, ErrMetricNotFound)
+	}
+	return nil
+}
 
-	metrics := make([]Metric, 0)
-
-	for rows.Next() {
-		var m Metric
-		if err != nil {
-			return "", fmt.Errorf("cannot scan row: %w", err)
-		}
-		if mType == GaugeType {
-			err = rows.Scan(&m.metricName, &m.metricValue)
-			if err != nil {
-				return "", fmt.Errorf("cannot get gauge metric: %w", err)
-			}
-		} else {
-			err = rows.Scan(&m.metricName, &m.metricValue)
-			if err != nil {
-				return "", fmt.Errorf("cannot get counter metric: %w", err)
-			}
-		}
-		metrics = append(metrics, m)
+func (d *DBStorage) ResetMetric(mType, mName string) error {
+	if mType != CounterType {
+		return fmt.Errorf("only counters can be reset, got %v", mType)
 	}
 
-	err = rows.Err()
+	ctx := context.Background()
+	tag, err := d.pool.Exec(ctx, "UPDATE countermetrics SET metricValue = 0, updatedat = now() WHERE metricName=$1", mName)
 	if err != nil {
-		return "", fmt.Errorf("error fetching rows from the db: %w", err)
+		return fmt.Errorf("cannot execute query while resetting metric: %w", err)
 	}
-	html := ""
-	for _, v := range metrics {
-		html += v.metricName + " : " + v.metricValue + "<br>"
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s %w", CounterType, ErrMetricNotFound)
 	}
+	return nil
+}
+
+func (d *DBStorage) SaveState(key string, data []byte) error {
+	sqlInsert := "INSERT INTO serverstate (statekey, statedata, updatedat) VALUES ($1, $2, now())" +
+		"ON CONFLICT (statekey) DO UPDATE SET statedata = $2, updatedat = now()"
 
-	return html, nil
+	ctx := context.Background()
+	if _, err := d.pool.Exec(ctx, sqlInsert, key, data); err != nil {
+		return fmt.Errorf("cannot execute query while saving state: %w", err)
+	}
+	return nil
+}
+
+func (d *DBStorage) LoadState(key string) ([]byte, error) {
+	ctx := context.Background()
+	row := d.pool.QueryRow(ctx, "SELECT statedata FROM serverstate WHERE statekey=$1", key)
+
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrStateNotFound
+		}
+		return nil, fmt.Errorf("cannot get state: %w", err)
+	}
+	return data, nil
 }
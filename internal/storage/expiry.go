@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"go-yandex-metrics/internal/config"
+)
+
+// Expiry tells stale series, the ones not updated for their TTL, from live
+// ones, and expired series, stale for longer than the delete delay as well.
+type Expiry struct {
+	rules       []config.TTLRule
+	deleteAfter time.Duration
+}
+
+// NewExpiry orders the rules so that the most specific one comes first: the
+// longest prefix, and a rule for the type before a rule for any type.
+func NewExpiry(cfg config.ExpiryCfg) *Expiry {
+	rules := append([]config.TTLRule(nil), cfg.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		if len(rules[i].Prefix) != len(rules[j].Prefix) {
+			return len(rules[i].Prefix) > len(rules[j].Prefix)
+		}
+		return rules[i].MType != "" && rules[j].MType == ""
+	})
+	return &Expiry{rules: rules, deleteAfter: cfg.DeleteAfter}
+}
+
+// Enabled reports whether any series can ever expire.
+func (e *Expiry) Enabled() bool {
+	for _, r := range e.rules {
+		if r.TTL > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// TTL returns the TTL of the series; zero means it never expires.
+func (e *Expiry) TTL(mType, id string) time.Duration {
+	for _, r := range e.rules {
+		if (r.MType == "" || r.MType == mType) && strings.HasPrefix(id, r.Prefix) {
+			return r.TTL
+		}
+	}
+	return 0
+}
+
+// Stale reports whether the metric has not been updated for its TTL. Metrics
+// without an update time are never stale.
+func (e *Expiry) Stale(m Metric, now time.Time) bool {
+	ttl := e.TTL(m.MType, m.ID)
+	return ttl > 0 && !m.UpdatedAt.IsZero() && now.Sub(m.UpdatedAt) > ttl
+}
+
+// Expired reports whether the metric has been stale for the delete delay.
+func (e *Expiry) Expired(m Metric, now time.Time) bool {
+	before, ok := e.ExpiredBefore(m.MType, m.ID, now)
+	return ok && !m.UpdatedAt.IsZero() && m.UpdatedAt.Before(before)
+}
+
+// ExpiredBefore returns the time a series has to be last updated before to
+// be expired as of now; false means the series never expires.
+func (e *Expiry) ExpiredBefore(mType, id string, now time.Time) (time.Time, bool) {
+	ttl := e.TTL(mType, id)
+	if ttl <= 0 {
+		return time.Time{}, false
+	}
+	return now.Add(-(ttl + e.deleteAfter)), true
+}
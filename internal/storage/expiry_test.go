@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := NewExpiry(config.ExpiryCfg{
+		Rules: []config.TTLRule{
+			{Prefix: "Disk", TTL: 2 * time.Hour},
+			{MType: GaugeType, TTL: time.Hour},
+			{MType: GaugeType, Prefix: "Disk", TTL: 30 * time.Minute},
+			{MType: CounterType, Prefix: "Poll", TTL: 0},
+		},
+		DeleteAfter: time.Hour,
+	})
+
+	tests := []struct {
+		name        string
+		metric      Metric
+		wantTTL     time.Duration
+		wantStale   bool
+		wantExpired bool
+	}{
+		{
+			name:    "fresh gauge",
+			metric:  Metric{MType: GaugeType, ID: "Alloc", UpdatedAt: now.Add(-time.Minute)},
+			wantTTL: time.Hour,
+		},
+		{
+			name:      "stale gauge",
+			metric:    Metric{MType: GaugeType, ID: `Alloc{agent="a1"}`, UpdatedAt: now.Add(-90 * time.Minute)},
+			wantTTL:   time.Hour,
+			wantStale: true,
+		},
+		{
+			name:        "expired gauge",
+			metric:      Metric{MType: GaugeType, ID: "Alloc", UpdatedAt: now.Add(-3 * time.Hour)},
+			wantTTL:     time.Hour,
+			wantStale:   true,
+			wantExpired: true,
+		},
+		{
+			name:      "typed prefix rule wins over the any type one",
+			metric:    Metric{MType: GaugeType, ID: "DiskFree", UpdatedAt: now.Add(-time.Hour)},
+			wantTTL:   30 * time.Minute,
+			wantStale: true,
+		},
+		{
+			name:    "prefix rule for any type",
+			metric:  Metric{MType: CounterType, ID: "DiskWrites", UpdatedAt: now.Add(-time.Hour)},
+			wantTTL: 2 * time.Hour,
+		},
+		{
+			name:   "zero TTL never expires",
+			metric: Metric{MType: CounterType, ID: "PollCount", UpdatedAt: now.Add(-100 * time.Hour)},
+		},
+		{
+			name:   "no rule for the series",
+			metric: Metric{MType: CounterType, ID: "Requests", UpdatedAt: now.Add(-100 * time.Hour)},
+		},
+		{
+			name:    "unknown update time",
+			metric:  Metric{MType: GaugeType, ID: "Alloc"},
+			wantTTL: time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, e.TTL(tt.metric.MType, tt.metric.ID), tt.wantTTL)
+			assert.Equal(t, e.Stale(tt.metric, now), tt.wantStale)
+			assert.Equal(t, e.Expired(tt.metric, now), tt.wantExpired)
+		})
+	}
+
+	assert.Equal(t, e.Enabled(), true)
+	assert.Equal(t, NewExpiry(config.ExpiryCfg{Rules: []config.TTLRule{{MType: GaugeType}}}).Enabled(), false)
+}
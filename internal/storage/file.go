@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"go-yandex-metrics/internal/config"
 )
@@ -53,6 +54,7 @@ func LoadMetrics(f *FileStorage, filePath string) error {
 		if err := json.Unmarshal(data, f); err != nil {
 			return fmt.Errorf("cannot unmarshal storage file, file is probably empty: %w", err)
 		}
+		f.MemStore.touchUntracked(time.Now())
 		return nil
 	}
 }
@@ -71,11 +73,12 @@ func SaveMetrics(s Storage, filePath string) error {
 	return nil
 }
 
-func (f *FileStorage) SaveMetric(mType, mName, mValue string) error {
-	if err := f.MemStore.SaveMetric(mType, mName, mValue); err != nil {
-		return fmt.Errorf("cannot save metric: %w", err)
+func (f *FileStorage) SaveMetric(mType, mName, mValue string) (Metric, error) {
+	metric, err := f.MemStore.SaveMetric(mType, mName, mValue)
+	if err != nil {
+		return metric, fmt.Errorf("cannot save metric: %w", err)
 	}
-	return nil
+	return metric, nil
 }
 
 func (f *FileStorage) GetMetric(mType, mName string) (string, error) {
@@ -86,10 +89,46 @@ func (f *FileStorage) GetMetric(mType, mName string) (string, error) {
 	return val, nil
 }
 
-func (f *FileStorage) GetAllMetrics() (string, error) {
-	metrics, err := f.MemStore.GetAllMetrics()
+func (f *FileStorage) ListMetrics() ([]Metric, error) {
+	metrics, err := f.MemStore.ListMetrics()
 	if err != nil {
-		return "", fmt.Errorf("an error occured getting a list of metricsc: %w", err)
+		return nil, fmt.Errorf("cannot list metrics: %w", err)
 	}
 	return metrics, nil
 }
+
+func (f *FileStorage) DeleteMetric(mType, mName string) error {
+	if err := f.MemStore.DeleteMetric(mType, mName); err != nil {
+		return fmt.Errorf("cannot delete metric: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStorage) DeleteStaleMetric(mType, mName string, before time.Time) error {
+	if err := f.MemStore.DeleteStaleMetric(mType, mName, before); err != nil {
+		return fmt.Errorf("cannot delete stale metric: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStorage) ResetMetric(mType, mName string) error {
+	if err := f.MemStore.ResetMetric(mType, mName); err != nil {
+		return fmt.Errorf("cannot reset metric: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStorage) SaveState(key string, data []byte) error {
+	if err := f.MemStore.SaveState(key, data); err != nil {
+		return fmt.Errorf("cannot save state: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStorage) LoadState(key string) ([]byte, error) {
+	data, err := f.MemStore.LoadState(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load state: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sample is the value of a series at a point in time; for counters it is the
+// running total.
+type Sample struct {
+	At    time.Time `json:"at"`
+	Value float64   `json:"value"`
+}
+
+// History keeps timestamped samples of every series in memory for the
+// retention period, so that rates can be computed from running totals.
+type History struct {
+	mu        *sync.RWMutex
+	series    map[string]map[string][]Sample
+	retention time.Duration
+}
+
+func NewHistory(retention time.Duration) *History {
+	return &History{
+		mu:        &sync.RWMutex{},
+		series:    make(map[string]map[string][]Sample),
+		retention: retention,
+	}
+}
+
+func (h *History) Retention() time.Duration {
+	return h.retention
+}
+
+// Record appends a sample and drops the ones past retention, except for the
+// last of them, which is the value at the start of the retention window.
+func (h *History) Record(mType, id string, value float64, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	byID, ok := h.series[mType]
+	if !ok {
+		byID = make(map[string][]Sample)
+		h.series[mType] = byID
+	}
+
+	samples := byID[id]
+	if n := len(samples); n > 0 && at.Before(samples[n-1].At) {
+		at = samples[n-1].At
+	}
+	byID[id] = trim(append(samples, Sample{At: at, Value: value}), at.Add(-h.retention))
+}
+
+// Prune forgets the samples past retention and the series left without
+// recent samples.
+func (h *History) Prune(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cutoff := now.Add(-h.retention)
+	for mType, byID := range h.series {
+		for id, samples := range byID {
+			if samples[len(samples)-1].At.Before(cutoff) {
+				delete(byID, id)
+				continue
+			}
+			byID[id] = trim(samples, cutoff)
+		}
+		if len(byID) == 0 {
+			delete(h.series, mType)
+		}
+	}
+}
+
+// MarshalJSON snapshots the samples of every series, so that they can be
+// kept with SaveState and restored after a restart.
+func (h *History) MarshalJSON() ([]byte, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	data, err := json.Marshal(h.series)
+	if err != nil {
+		return nil, fmt.Errorf("failed to JSON encode history: %w", err)
+	}
+	return data, nil
+}
+
+// Restore replaces the samples with a snapshot made by MarshalJSON, dropping
+// the ones that went past retention in the meantime.
+func (h *History) Restore(data []byte, now time.Time) error {
+	var series map[string]map[string][]Sample
+	if err := json.Unmarshal(data, &series); err != nil {
+		return fmt.Errorf("failed to decode history: %w", err)
+	}
+	if series == nil {
+		series = make(map[string]map[string][]Sample)
+	}
+	for _, byID := range series {
+		for id, samples := range byID {
+			if len(samples) == 0 {
+				delete(byID, id)
+			}
+		}
+	}
+
+	h.mu.Lock()
+	h.series = series
+	h.mu.Unlock()
+
+	h.Prune(now)
+	return nil
+}
+
+// Delete forgets all samples of a series.
+func (h *History) Delete(mType, id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.series[mType], id)
+}
+
+// Series returns the sorted IDs of the series of the type that have samples.
+func (h *History) Series(mType string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ids := make([]string, 0, len(h.series[mType]))
+	for id := range h.series[mType] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Range returns the samples taken within [from, to] preceded by the last one
+// taken before from, if any, which is the value of the series at from.
+func (h *History) Range(mType, id string, from, to time.Time) []Sample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.series[mType][id]
+	start := sort.Search(len(samples), func(i int) bool { return !samples[i].At.Before(from) })
+	end := sort.Search(len(samples), func(i int) bool { return samples[i].At.After(to) })
+	if start > 0 && (start == len(samples) || samples[start].At.After(from)) {
+		start--
+	}
+	if start >= end {
+		return nil
+	}
+	return append([]Sample(nil), samples[start:end]...)
+}
+
+// Rate is ComputeRate over the window that ends at now.
+func (h *History) Rate(mType, id string, window time.Duration, now time.Time) (Rate, bool) {
+	from := now.Add(-window)
+	return ComputeRate(h.Range(mType, id, from, now), from, now, mType == CounterType)
+}
+
+func trim(samples []Sample, cutoff time.Time) []Sample {
+	keep := sort.Search(len(samples), func(i int) bool { return !samples[i].At.Before(cutoff) })
+	if keep > 0 && (keep == len(samples) || samples[keep].At.After(cutoff)) {
+		keep--
+	}
+	// Начало массива не копируется: оно освобождается, когда append в
+	// следующий раз перевыделит память под растущий хвост.
+	return samples[keep:]
+}
+
+// Rate is the change of a series over a window.
+type Rate struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Increase float64   `json:"increase"`
+	Rate     float64   `json:"rate"`
+}
+
+// ComputeRate returns the increase of a series over [from, to] and its
+// per-second rate, given samples as returned by History.Range. A counter
+// going down means it was reset, so its new total is all increase. A sample
+// taken at or before from stands for the value at from; otherwise the window
+// starts at the first sample. A window with a single sample of its own has no
+// rate.
+func ComputeRate(samples []Sample, from, to time.Time, counter bool) (Rate, bool) {
+	if len(samples) == 0 {
+		return Rate{}, false
+	}
+
+	start := samples[0].At
+	if !start.After(from) {
+		start = from
+	} else if len(samples) < 2 {
+		return Rate{}, false
+	}
+	if !to.After(start) {
+		return Rate{}, false
+	}
+
+	r := Rate{From: start, To: to}
+	for i := 1; i < len(samples); i++ {
+		delta := samples[i].Value - samples[i-1].Value
+		if counter && delta < 0 {
+			delta = samples[i].Value
+		}
+		r.Increase += delta
+	}
+	r.Rate = r.Increase / to.Sub(start).Seconds()
+	return r, true
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+)
+
+func TestComputeRate(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		name     string
+		samples  []Sample
+		from, to time.Time
+		counter  bool
+		want     Rate
+		wantOK   bool
+	}{
+		{
+			name:    "counter",
+			samples: []Sample{{At: at(1), Value: 10}, {At: at(2), Value: 70}, {At: at(3), Value: 130}},
+			from:    at(0),
+			to:      at(3),
+			counter: true,
+			want:    Rate{From: at(1), To: at(3), Increase: 120, Rate: 1},
+			wantOK:  true,
+		},
+		{
+			name:    "counter reset",
+			samples: []Sample{{At: at(0), Value: 100}, {At: at(1), Value: 30}, {At: at(2), Value: 90}},
+			from:    at(0),
+			to:      at(2),
+			counter: true,
+			want:    Rate{From: at(0), To: at(2), Increase: 90, Rate: 0.75},
+			wantOK:  true,
+		},
+		{
+			name:    "gauge goes down",
+			samples: []Sample{{At: at(0), Value: 100}, {At: at(1), Value: 40}},
+			from:    at(0),
+			to:      at(1),
+			want:    Rate{From: at(0), To: at(1), Increase: -60, Rate: -1},
+			wantOK:  true,
+		},
+		{
+			name:    "no samples since the window start",
+			samples: []Sample{{At: at(-3), Value: 100}},
+			from:    at(0),
+			to:      at(5),
+			counter: true,
+			want:    Rate{From: at(0), To: at(5)},
+			wantOK:  true,
+		},
+		{
+			name:    "single sample",
+			samples: []Sample{{At: at(1), Value: 100}},
+			from:    at(0),
+			to:      at(5),
+			counter: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ComputeRate(tt.samples, tt.from, tt.to, tt.counter)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestHistory_Range(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHistory(10 * time.Minute)
+	for i := range 20 {
+		h.Record(CounterType, "PollCount", float64(i), start.Add(time.Duration(i)*time.Minute))
+	}
+
+	// Самый старый замер за пределами хранения остаётся как значение на начало окна.
+	all := h.Range(CounterType, "PollCount", start, start.Add(time.Hour))
+	assert.Equal(t, all[0], Sample{At: start.Add(9 * time.Minute), Value: 9})
+	assert.Equal(t, len(all), 11)
+
+	window := h.Range(CounterType, "PollCount", start.Add(90*time.Second+10*time.Minute), start.Add(13*time.Minute))
+	assert.Equal(t, window, []Sample{
+		{At: start.Add(11 * time.Minute), Value: 11},
+		{At: start.Add(12 * time.Minute), Value: 12},
+		{At: start.Add(13 * time.Minute), Value: 13},
+	})
+
+	h.Prune(start.Add(time.Hour))
+	assert.Equal(t, h.Series(CounterType), []string{})
+}
+
+func TestHistory_Restore(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHistory(10 * time.Minute)
+	for i := range 1000 {
+		h.Record(CounterType, "PollCount", float64(i), start.Add(time.Duration(i)*time.Second))
+	}
+	h.Record(GaugeType, "Alloc", 1, start)
+
+	data, err := h.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewHistory(10 * time.Minute)
+	now := start.Add(1000 * time.Second)
+	if err := restored.Restore(data, now); err != nil {
+		t.Fatal(err)
+	}
+	from := now.Add(-5 * time.Minute)
+	assert.Equal(t, restored.Range(CounterType, "PollCount", from, now), h.Range(CounterType, "PollCount", from, now))
+	// Замер, вышедший за пределы хранения за время простоя, не восстанавливается.
+	assert.Equal(t, restored.Series(GaugeType), []string{})
+
+	restored.Record(CounterType, "PollCount", 1000, now)
+	assert.Equal(t, len(restored.Range(CounterType, "PollCount", start, now)), 601)
+
+	assert.NotEqual(t, restored.Restore([]byte(`{`), now), nil)
+	assert.Equal(t, restored.Restore([]byte(`null`), now), nil)
+	restored.Record(GaugeType, "Alloc", 2, now)
+	assert.Equal(t, restored.Series(GaugeType), []string{"Alloc"})
+}
@@ -1,10 +1,12 @@
 package storage
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"strconv"
 	"sync"
+	"time"
 
 	"go-yandex-metrics/internal/config"
 )
@@ -15,8 +17,11 @@ const (
 )
 
 type MemStorage struct {
-	Gauge   map[string]float64 `json:"gauge"`
-	Counter map[string]int64   `json:"counter"`
+	Gauge   map[string]float64         `json:"gauge"`
+	Counter map[string]int64           `json:"counter"`
+	State   map[string]json.RawMessage `json:"state,omitempty"`
+	// Updated keeps the last save time of every series by type and name.
+	Updated map[string]map[string]time.Time `json:"updated,omitempty"`
 	memLock *sync.Mutex
 }
 
@@ -24,40 +29,72 @@ func NewMemStorage(cfg *config.ServerCfg) (*MemStorage, error) {
 	return &MemStorage{
 		Gauge:   make(map[string]float64),
 		Counter: make(map[string]int64),
+		State:   make(map[string]json.RawMessage),
+		Updated: make(map[string]map[string]time.Time),
 		memLock: &sync.Mutex{},
 	}, nil
 }
 
-func (m *MemStorage) SaveMetric(mType, mName, mValue string) error {
+// MarshalJSON copies the maps under the lock, so that the storage can be
+// saved to a file while metrics and states are being written.
+func (m *MemStorage) MarshalJSON() ([]byte, error) {
+	type snapshot MemStorage
+
+	m.memLock.Lock()
+	snap := snapshot{
+		Gauge:   maps.Clone(m.Gauge),
+		Counter: maps.Clone(m.Counter),
+		State:   maps.Clone(m.State),
+		Updated: make(map[string]map[string]time.Time, len(m.Updated)),
+	}
+	for mType, updated := range m.Updated {
+		snap.Updated[mType] = maps.Clone(updated)
+	}
+	m.memLock.Unlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal memory storage: %w", err)
+	}
+	return data, nil
+}
+
+func (m *MemStorage) SaveMetric(mType, mName, mValue string) (Metric, error) {
 	switch mType {
 	case CounterType:
-		if err := m.saveCounter(mName, mValue); err != nil {
-			return fmt.Errorf("failed to save counter: %w", err)
+		metric, err := m.saveCounter(mName, mValue)
+		if err != nil {
+			return metric, fmt.Errorf("failed to save counter: %w", err)
 		}
+		return metric, nil
 	case GaugeType:
-		if err := m.saveGauge(mName, mValue); err != nil {
-			return fmt.Errorf("failed to save gauge: %w", err)
+		metric, err := m.saveGauge(mName, mValue)
+		if err != nil {
+			return metric, fmt.Errorf("failed to save gauge: %w", err)
 		}
+		return metric, nil
 	default:
-		return fmt.Errorf("wrong metric type: %v", mType)
+		return Metric{}, fmt.Errorf("wrong metric type: %v", mType)
 	}
-	return nil
 }
 
 func (m *MemStorage) GetMetric(mType, mName string) (string, error) {
 	var html string
 
+	m.memLock.Lock()
+	defer m.memLock.Unlock()
+
 	switch mType {
 	case GaugeType:
 		if mValue, ok := m.Gauge[mName]; !ok {
-			return "", errors.New(GaugeType + " metric not found")
+			return "", fmt.Errorf("%s %w", GaugeType, ErrMetricNotFound)
 		} else {
 			html = strconv.FormatFloat(mValue, 'f', -1, 64)
 			return html, nil
 		}
 	case CounterType:
 		if mValue, ok := m.Counter[mName]; !ok {
-			return "", errors.New(CounterType + " metric not found")
+			return "", fmt.Errorf("%s %w", CounterType, ErrMetricNotFound)
 		} else {
 			html = strconv.FormatInt(mValue, 10)
 			return html, nil
@@ -67,40 +104,166 @@ func (m *MemStorage) GetMetric(mType, mName string) (string, error) {
 	}
 }
 
-func (m *MemStorage) GetAllMetrics() (string, error) {
-	html := "<h3>Gauge:</h3>"
+func (m *MemStorage) ListMetrics() ([]Metric, error) {
+	m.memLock.Lock()
+	defer m.memLock.Unlock()
+
+	metrics := make([]Metric, 0, len(m.Gauge)+len(m.Counter))
 	for mName, mValue := range m.Gauge {
-		html += (mName + ":" + strconv.FormatFloat(mValue, 'f', -1, 64) + "<br>")
+		metrics = append(metrics, Metric{
+			MType: GaugeType, ID: mName, Value: mValue, UpdatedAt: m.Updated[GaugeType][mName],
+		})
 	}
-	html += "<h3>Counter:</h3>"
 	for mName, mValue := range m.Counter {
-		html += (mName + ":" + strconv.FormatInt(mValue, 10) + "<br>")
+		metrics = append(metrics, Metric{
+			MType: CounterType, ID: mName, Delta: mValue, UpdatedAt: m.Updated[CounterType][mName],
+		})
+	}
+	return metrics, nil
+}
+
+func (m *MemStorage) DeleteMetric(mType, mName string) error {
+	return m.deleteMetric(mType, mName, time.Time{})
+}
+
+func (m *MemStorage) DeleteStaleMetric(mType, mName string, before time.Time) error {
+	return m.deleteMetric(mType, mName, before)
+}
+
+// deleteMetric removes a series; with before set, only if it has not been
+// saved since.
+func (m *MemStorage) deleteMetric(mType, mName string, before time.Time) error {
+	m.memLock.Lock()
+	defer m.memLock.Unlock()
+
+	var ok bool
+	switch mType {
+	case GaugeType:
+		_, ok = m.Gauge[mName]
+	case CounterType:
+		_, ok = m.Counter[mName]
+	default:
+		return fmt.Errorf("wrong metric type: %v", mType)
+	}
+	if ok && !before.IsZero() {
+		updated, tracked := m.Updated[mType][mName]
+		ok = tracked && updated.Before(before)
+	}
+	if !ok {
+		return fmt.Errorf("%s %w", mType, ErrMetricNotFound)
+	}
+
+	if mType == GaugeType {
+		delete(m.Gauge, mName)
+	} else {
+		delete(m.Counter, mName)
+	}
+	delete(m.Updated[mType], mName)
+	return nil
+}
+
+func (m *MemStorage) ResetMetric(mType, mName string) error {
+	if mType != CounterType {
+		return fmt.Errorf("only counters can be reset, got %v", mType)
+	}
+
+	m.memLock.Lock()
+	defer m.memLock.Unlock()
+
+	if _, ok := m.Counter[mName]; !ok {
+		return fmt.Errorf("%s %w", CounterType, ErrMetricNotFound)
+	}
+	m.Counter[mName] = 0
+	m.touch(CounterType, mName, time.Now())
+	return nil
+}
+
+func (m *MemStorage) SaveState(key string, data []byte) error {
+	if !json.Valid(data) {
+		return fmt.Errorf("state %s is not valid JSON", key)
 	}
-	return html, nil
+
+	m.memLock.Lock()
+	defer m.memLock.Unlock()
+
+	if m.State == nil {
+		m.State = make(map[string]json.RawMessage)
+	}
+	m.State[key] = append(json.RawMessage(nil), data...)
+	return nil
 }
 
-func (m *MemStorage) saveCounter(mName, mValue string) error {
+func (m *MemStorage) LoadState(key string) ([]byte, error) {
+	m.memLock.Lock()
+	defer m.memLock.Unlock()
+
+	data, ok := m.State[key]
+	if !ok {
+		return nil, ErrStateNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *MemStorage) saveCounter(mName, mValue string) (Metric, error) {
+	metric := Metric{MType: CounterType, ID: mName}
 	vFloat64, err := strconv.ParseFloat(mValue, 64)
 	if err != nil {
-		return fmt.Errorf("got error parsing float value for counter metric: %w", err)
+		return metric, fmt.Errorf("got error parsing float value for counter metric: %w", err)
 	}
 
 	vInt64 := int64(vFloat64)
+	metric.UpdatedAt = time.Now()
 	m.memLock.Lock()
 	m.Counter[mName] += vInt64
+	metric.Delta = m.Counter[mName]
+	m.touch(CounterType, mName, metric.UpdatedAt)
 	m.memLock.Unlock()
 
-	return nil
+	return metric, nil
 }
 
-func (m *MemStorage) saveGauge(mName, mValue string) error {
+func (m *MemStorage) saveGauge(mName, mValue string) (Metric, error) {
+	metric := Metric{MType: GaugeType, ID: mName}
 	vFloat64, err := strconv.ParseFloat(mValue, 64)
 	if err != nil {
-		return fmt.Errorf("got error parsing float value for gauge metric: %w", err)
+		return metric, fmt.Errorf("got error parsing float value for gauge metric: %w", err)
 	}
 
+	metric.Value, metric.UpdatedAt = vFloat64, time.Now()
 	m.memLock.Lock()
 	m.Gauge[mName] = vFloat64
+	m.touch(GaugeType, mName, metric.UpdatedAt)
 	m.memLock.Unlock()
-	return nil
+	return metric, nil
+}
+
+// touch records the save time of a series; the caller holds memLock.
+func (m *MemStorage) touch(mType, mName string, at time.Time) {
+	if m.Updated == nil {
+		m.Updated = make(map[string]map[string]time.Time)
+	}
+	byName, ok := m.Updated[mType]
+	if !ok {
+		byName = make(map[string]time.Time)
+		m.Updated[mType] = byName
+	}
+	byName[mName] = at
+}
+
+// touchUntracked gives series restored from a file written before save
+// times were kept the time of the restore, so that they do not expire at once.
+func (m *MemStorage) touchUntracked(at time.Time) {
+	m.memLock.Lock()
+	defer m.memLock.Unlock()
+
+	for mName := range m.Gauge {
+		if _, ok := m.Updated[GaugeType][mName]; !ok {
+			m.touch(GaugeType, mName, at)
+		}
+	}
+	for mName := range m.Counter {
+		if _, ok := m.Updated[CounterType][mName]; !ok {
+			m.touch(CounterType, mName, at)
+		}
+	}
 }
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+
+	"go-yandex-metrics/internal/config"
+)
+
+func TestMemStorage_SaveMetric(t *testing.T) {
+	store, err := NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gauge, err := store.SaveMetric(GaugeType, "Alloc", "1.5")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, gauge.Value, 1.5)
+	assert.Equal(t, gauge.UpdatedAt.IsZero(), false)
+
+	_, err = store.SaveMetric(CounterType, "PollCount", "x")
+	assert.NotEqual(t, err, nil)
+
+	// Каждая запись возвращает свою сумму, даже при параллельных записях.
+	const writers = 50
+	totals := make(chan int64, writers)
+	wg := &sync.WaitGroup{}
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := store.SaveMetric(CounterType, "PollCount", "1")
+			if err != nil {
+				t.Error(err)
+			}
+			totals <- m.Delta
+		}()
+	}
+	wg.Wait()
+	close(totals)
+
+	seen := make(map[int64]bool)
+	for total := range totals {
+		seen[total] = true
+	}
+	assert.Equal(t, len(seen), writers)
+	for i := int64(1); i <= writers; i++ {
+		assert.Equal(t, seen[i], true)
+	}
+}
+
+func TestMemStorage_DeleteStaleMetric(t *testing.T) {
+	store, err := NewMemStorage(&config.ServerCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SaveMetric(GaugeType, "Alloc", "1"); err != nil {
+		t.Fatal(err)
+	}
+	saved := store.Updated[GaugeType]["Alloc"]
+
+	// Серия обновлена позже границы, поэтому не удаляется.
+	err = store.DeleteStaleMetric(GaugeType, "Alloc", saved)
+	assert.Equal(t, errors.Is(err, ErrMetricNotFound), true)
+	_, err = store.GetMetric(GaugeType, "Alloc")
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, store.DeleteStaleMetric(GaugeType, "Alloc", saved.Add(time.Second)), nil)
+	_, err = store.GetMetric(GaugeType, "Alloc")
+	assert.Equal(t, errors.Is(err, ErrMetricNotFound), true)
+
+	err = store.DeleteStaleMetric(CounterType, "PollCount", saved.Add(time.Second))
+	assert.Equal(t, errors.Is(err, ErrMetricNotFound), true)
+}
+
+func TestMemStorage_MarshalJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	store, err := NewFileStorage(&config.ServerCfg{StorageCfg: config.StorageCfg{FileStoragePath: path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Сохранение в файл идёт параллельно с записями и не должно с ними гоняться.
+	wg := &sync.WaitGroup{}
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.SaveMetric(CounterType, "PollCount", "1"); err != nil {
+				t.Error(err)
+			}
+			if err := store.SaveState("alerts", []byte(strconv.Itoa(i))); err != nil {
+				t.Error(err)
+			}
+			if err := SaveMetrics(store, path); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, SaveMetrics(store, path), nil)
+
+	restored, err := NewFileStorage(&config.ServerCfg{
+		StorageCfg: config.StorageCfg{FileStoragePath: path, Restore: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, err := restored.GetMetric(CounterType, "PollCount")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, "20")
+	assert.Equal(t, restored.MemStore.Updated[CounterType]["PollCount"].IsZero(), false)
+}
@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE gaugemetrics ALTER COLUMN metricname TYPE TEXT;
+
+ALTER TABLE countermetrics ALTER COLUMN metricname TYPE TEXT;
+
+COMMIT;
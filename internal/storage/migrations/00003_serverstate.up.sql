@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS serverstate (
+	statekey TEXT PRIMARY KEY,
+	statedata JSONB NOT NULL,
+	updatedat TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMIT;
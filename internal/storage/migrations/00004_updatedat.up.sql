@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE gaugemetrics ADD COLUMN IF NOT EXISTS updatedat TIMESTAMPTZ NOT NULL DEFAULT now();
+
+ALTER TABLE countermetrics ADD COLUMN IF NOT EXISTS updatedat TIMESTAMPTZ NOT NULL DEFAULT now();
+
+COMMIT;
@@ -1,15 +1,46 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"go-yandex-metrics/internal/config"
 )
 
 type Storage interface {
-	SaveMetric(mType, mName, mValue string) error
+	// SaveMetric returns the series as the write left it, with the running
+	// total for counters, so callers need not read it back.
+	SaveMetric(mType, mName, mValue string) (Metric, error)
 	GetMetric(mType, mName string) (string, error)
-	GetAllMetrics() (string, error)
+	ListMetrics() ([]Metric, error)
+	// DeleteMetric removes a series and ResetMetric sets a counter back to
+	// zero; both return ErrMetricNotFound for unknown series.
+	DeleteMetric(mType, mName string) error
+	ResetMetric(mType, mName string) error
+	// DeleteStaleMetric removes a series only if it was last saved before
+	// the given time, checking and deleting at once, so that a series saved
+	// in the meantime is kept; ErrMetricNotFound is returned otherwise.
+	DeleteStaleMetric(mType, mName string, before time.Time) error
+	// SaveState and LoadState keep JSON documents of server subsystems, such
+	// as alert states, next to the metrics so that they survive restarts.
+	SaveState(key string, data []byte) error
+	LoadState(key string) ([]byte, error)
+}
+
+var (
+	ErrMetricNotFound = errors.New("metric not found")
+	ErrStateNotFound  = errors.New("state not found")
+)
+
+// Metric is a stored series; Value is set for gauges and Delta, the running
+// total, for counters. UpdatedAt is when the series was last saved.
+type Metric struct {
+	UpdatedAt time.Time
+	MType     string
+	ID        string
+	Value     float64
+	Delta     int64
 }
 
 func NewStore(cfg *config.ServerCfg) (Storage, error) {
@@ -40,3 +71,11 @@ func NewStore(cfg *config.ServerCfg) (Storage, error) {
 		return store, nil
 	}
 }
+
+// Float64 returns the value of a gauge or the running total of a counter.
+func (m Metric) Float64() float64 {
+	if m.MType == CounterType {
+		return float64(m.Delta)
+	}
+	return m.Value
+}
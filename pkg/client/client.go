@@ -0,0 +1,184 @@
+// Package client pushes metrics to the metrics server from inside a Go
+// service, without running a separate agent:
+//
+//	c, err := client.New("localhost:8080", client.WithKey("secret"))
+//	...
+//	defer c.Close()
+//	c.Gauge("QueueLength").Set(12)
+//	c.Counter("OrdersCreated").Add(1)
+//
+// Values are buffered and sent in batches every flush interval and on Close.
+// Values set after Close are dropped and ErrClosed goes to the error handler.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrClosed = errors.New("client is closed")
+
+type Client struct {
+	sender   *Sender
+	mu       *sync.Mutex
+	gauges   map[string]float64
+	counters map[string]int64
+	onError  func(error)
+	done     chan struct{}
+	wg       *sync.WaitGroup
+	interval time.Duration
+	closed   bool
+}
+
+func New(host string, opts ...Option) (*Client, error) {
+	sender, err := NewSender(host, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	if o.flushInterval <= 0 {
+		return nil, fmt.Errorf("flush interval must be positive, got %s", o.flushInterval)
+	}
+
+	c := &Client{
+		sender:   sender,
+		mu:       &sync.Mutex{},
+		gauges:   make(map[string]float64),
+		counters: make(map[string]int64),
+		onError:  o.onError,
+		done:     make(chan struct{}),
+		wg:       &sync.WaitGroup{},
+		interval: o.flushInterval,
+	}
+
+	c.wg.Add(1)
+	go c.loop()
+
+	return c, nil
+}
+
+type Gauge struct {
+	c    *Client
+	name string
+}
+
+func (c *Client) Gauge(name string) *Gauge {
+	return &Gauge{c: c, name: name}
+}
+
+func (g *Gauge) Set(value float64) {
+	g.c.update(func() {
+		g.c.gauges[g.name] = value
+	})
+}
+
+type Counter struct {
+	c    *Client
+	name string
+}
+
+func (c *Client) Counter(name string) *Counter {
+	return &Counter{c: c, name: name}
+}
+
+func (cn *Counter) Add(delta int64) {
+	cn.c.update(func() {
+		cn.c.counters[cn.name] += delta
+	})
+}
+
+func (cn *Counter) Inc() {
+	cn.Add(1)
+}
+
+// update changes the buffer unless the client is closed, in which case
+// nothing would send the value anymore.
+func (c *Client) update(fn func()) {
+	c.mu.Lock()
+	closed := c.closed
+	if !closed {
+		fn()
+	}
+	c.mu.Unlock()
+
+	if closed && c.onError != nil {
+		c.onError(ErrClosed)
+	}
+}
+
+// Flush sends everything buffered so far. On failure counter deltas are
+// kept and go out with the next flush.
+func (c *Client) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	batch := make([]Metrics, 0, len(c.gauges)+len(c.counters))
+	for name, v := range c.gauges {
+		batch = append(batch, GaugeMetric(name, v))
+	}
+	for name, d := range c.counters {
+		batch = append(batch, CounterMetric(name, d))
+	}
+	gauges, counters := c.gauges, c.counters
+	c.gauges = make(map[string]float64)
+	c.counters = make(map[string]int64)
+	c.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := c.sender.SendBatch(ctx, batch)
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+
+	c.mu.Lock()
+	for name, v := range gauges {
+		if _, ok := c.gauges[name]; !ok {
+			c.gauges[name] = v
+		}
+	}
+	for name, d := range counters {
+		c.counters[name] += d
+	}
+	c.mu.Unlock()
+
+	return err
+}
+
+// Close stops background flushing and sends what is left in the buffer.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	close(c.done)
+	c.wg.Wait()
+
+	return c.Flush(context.Background())
+}
+
+func (c *Client) loop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.Flush(context.Background()); err != nil && c.onError != nil {
+				c.onError(err)
+			}
+		}
+	}
+}
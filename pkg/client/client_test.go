@@ -0,0 +1,142 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+)
+
+type receiver struct {
+	mu       *sync.Mutex
+	batches  [][]Metrics
+	hashes   []string
+	failures int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var batch []Metrics
+	if err := json.Unmarshal(data, &batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(data)
+	if r.Header.Get(HashHeader) != hex.EncodeToString(mac.Sum(nil)) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rc.batches = append(rc.batches, batch)
+	rc.hashes = append(rc.hashes, r.Header.Get(HashHeader))
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestClient_CloseFlushes(t *testing.T) {
+	rc := &receiver{mu: &sync.Mutex{}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithKey("secret"), WithGzip(), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Gauge("QueueLength").Set(3)
+	c.Gauge("QueueLength").Set(12)
+	c.Counter("OrdersCreated").Add(2)
+	c.Counter("OrdersCreated").Inc()
+
+	assert.Equal(t, c.Close(), nil)
+	assert.Equal(t, c.Close(), ErrClosed)
+
+	assert.Equal(t, len(rc.batches), 1)
+	got := make(map[string]Metrics)
+	for _, m := range rc.batches[0] {
+		got[m.ID] = m
+	}
+	assert.Equal(t, *got["QueueLength"].Value, float64(12))
+	assert.Equal(t, *got["OrdersCreated"].Delta, int64(3))
+}
+
+func TestClient_FailedFlushKeepsCounters(t *testing.T) {
+	rc := &receiver{mu: &sync.Mutex{}, failures: 1}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithKey("secret"), WithRetry(0, time.Millisecond, time.Millisecond),
+		WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Counter("OrdersCreated").Add(5)
+	assert.NotEqual(t, c.Flush(context.Background()), nil)
+
+	c.Counter("OrdersCreated").Add(1)
+	assert.Equal(t, c.Close(), nil)
+
+	assert.Equal(t, len(rc.batches), 1)
+	assert.Equal(t, *rc.batches[0][0].Delta, int64(6))
+}
+
+func TestClient_SetAfterClose(t *testing.T) {
+	rc := &receiver{mu: &sync.Mutex{}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	var errs []error
+	c, err := New(srv.URL, WithKey("secret"), WithFlushInterval(time.Hour),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Counter("OrdersCreated").Inc()
+	assert.Equal(t, c.Close(), nil)
+
+	c.Gauge("QueueLength").Set(1)
+	c.Counter("OrdersCreated").Add(2)
+	assert.Equal(t, errs, []error{ErrClosed, ErrClosed})
+	assert.Equal(t, c.Flush(context.Background()), nil)
+
+	assert.Equal(t, len(rc.batches), 1)
+	assert.Equal(t, len(rc.batches[0]), 1)
+	assert.Equal(t, *rc.batches[0][0].Delta, int64(1))
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP status code is not 200 OK: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// IsPermanent reports whether err is a response the server will keep
+// rejecting, so there is no point in sending the same request again.
+func IsPermanent(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return !isRetryableStatus(statusErr.StatusCode)
+}
+
+func isRetryableStatus(code int) bool {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return true
+	case code == http.StatusNotImplemented:
+		return false
+	case code >= http.StatusInternalServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+// CheckRetry retries connection errors, 429 and 5xx responses, and gives up
+// straight away on any other status such as 400.
+func CheckRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("request context is done: %w", ctx.Err())
+	}
+
+	if err != nil {
+		retry, policyErr := retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+		if policyErr != nil {
+			return retry, fmt.Errorf("retry policy failed: %w", policyErr)
+		}
+		return retry, nil
+	}
+
+	return isRetryableStatus(resp.StatusCode), nil
+}
+
+// Backoff waits exponentially longer on each attempt with random jitter,
+// staying within [minValue, maxValue]. A Retry-After header sent with 429 or
+// 503 takes precedence, clamped to the same bounds.
+func Backoff(minValue, maxValue time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return clampDuration(wait, minValue, maxValue)
+		}
+	}
+
+	wait := maxValue
+	if attemptNum < 63 {
+		if exp := minValue << attemptNum; exp > 0 && exp>>attemptNum == minValue {
+			wait = min(exp, maxValue)
+		}
+	}
+
+	half := wait / 2
+	if half > 0 {
+		wait = half + time.Duration(rand.Int63n(int64(half)+1))
+	}
+
+	return clampDuration(wait, minValue, maxValue)
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at), true
+	}
+
+	return 0, false
+}
+
+func clampDuration(d, minValue, maxValue time.Duration) time.Duration {
+	return max(minValue, min(d, maxValue))
+}
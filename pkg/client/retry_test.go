@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert"
+)
+
+func TestCheckRetry(t *testing.T) {
+	tests := []struct {
+		err  error
+		resp *http.Response
+		name string
+		want bool
+	}{
+		{name: "connection error", err: errors.New("connection refused"), want: true},
+		{name: "200 OK", resp: &http.Response{StatusCode: http.StatusOK}, want: false},
+		{name: "400 Bad Request", resp: &http.Response{StatusCode: http.StatusBadRequest}, want: false},
+		{name: "429 Too Many Requests", resp: &http.Response{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "500 Internal Server Error", resp: &http.Response{StatusCode: http.StatusInternalServerError}, want: true},
+		{name: "501 Not Implemented", resp: &http.Response{StatusCode: http.StatusNotImplemented}, want: false},
+		{name: "503 Service Unavailable", resp: &http.Response{StatusCode: http.StatusServiceUnavailable}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckRetry(context.Background(), tt.resp, tt.err)
+			assert.Equal(t, err, nil)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	const minWait = 100 * time.Millisecond
+	const maxWait = 2 * time.Second
+
+	tests := []struct {
+		resp    *http.Response
+		name    string
+		attempt int
+		lower   time.Duration
+		upper   time.Duration
+	}{
+		{name: "first attempt", attempt: 0, lower: minWait, upper: minWait},
+		{name: "third attempt", attempt: 2, lower: 200 * time.Millisecond, upper: 400 * time.Millisecond},
+		{name: "capped by max", attempt: 40, lower: time.Second, upper: maxWait},
+		{
+			name:    "Retry-After on 503",
+			attempt: 0,
+			resp: &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     http.Header{"Retry-After": []string{"1"}},
+			},
+			lower: time.Second,
+			upper: time.Second,
+		},
+		{
+			name:    "Retry-After clamped by max",
+			attempt: 0,
+			resp: &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": []string{"120"}},
+			},
+			lower: maxWait,
+			upper: maxWait,
+		},
+		{
+			name:    "Retry-After ignored on 500",
+			attempt: 0,
+			resp: &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Header:     http.Header{"Retry-After": []string{"1"}},
+			},
+			lower: minWait,
+			upper: minWait,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				got := Backoff(minWait, maxWait, tt.attempt, tt.resp)
+				if got < tt.lower || got > tt.upper {
+					t.Fatalf("Backoff() = %v, want between %v and %v", got, tt.lower, tt.upper)
+				}
+			}
+		})
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	assert.Equal(t, IsPermanent(&StatusError{StatusCode: http.StatusBadRequest}), true)
+	assert.Equal(t, IsPermanent(&StatusError{StatusCode: http.StatusServiceUnavailable}), false)
+	assert.Equal(t, IsPermanent(errors.New("connection refused")), false)
+}
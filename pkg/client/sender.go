@@ -0,0 +1,250 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	GaugeType   string = "gauge"
+	CounterType string = "counter"
+
+	updatePath  string = "update"
+	updatesPath string = "updates"
+
+	// HashHeader carries the hex HMAC-SHA256 of the request body when the
+	// sender is configured with a key.
+	HashHeader string = "HashSHA256"
+)
+
+type Metrics struct {
+	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
+	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
+	ID    string   `json:"id"`              // имя метрики
+}
+
+func GaugeMetric(id string, value float64) Metrics {
+	return Metrics{ID: id, MType: GaugeType, Value: &value}
+}
+
+func CounterMetric(id string, delta int64) Metrics {
+	return Metrics{ID: id, MType: CounterType, Delta: &delta}
+}
+
+type options struct {
+	headers       http.Header
+	onError       func(error)
+	key           []byte
+	retryMax      int
+	retryWaitMin  time.Duration
+	retryWaitMax  time.Duration
+	flushInterval time.Duration
+	gzip          bool
+}
+
+type Option func(*options)
+
+// WithKey signs every request body with HMAC-SHA256 using key.
+func WithKey(key string) Option {
+	return func(o *options) {
+		o.key = []byte(key)
+	}
+}
+
+// WithGzip compresses request bodies.
+func WithGzip() Option {
+	return func(o *options) {
+		o.gzip = true
+	}
+}
+
+func WithRetry(retryMax int, waitMin, waitMax time.Duration) Option {
+	return func(o *options) {
+		o.retryMax = retryMax
+		o.retryWaitMin = waitMin
+		o.retryWaitMax = waitMax
+	}
+}
+
+// WithHeader adds a header to every request, e.g. the agent identity.
+func WithHeader(key, value string) Option {
+	return func(o *options) {
+		o.headers.Add(key, value)
+	}
+}
+
+// WithFlushInterval sets how often a Client sends buffered metrics.
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.flushInterval = d
+	}
+}
+
+// WithErrorHandler is called with errors of background flushes and with
+// ErrClosed for values set after Close, which are otherwise dropped.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		headers:       http.Header{},
+		retryMax:      3,
+		retryWaitMin:  1 * time.Second,
+		retryWaitMax:  5 * time.Second,
+		flushInterval: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Sender posts metrics to the metrics server, retrying transient failures.
+type Sender struct {
+	client  *http.Client
+	headers http.Header
+	baseURL string
+	key     []byte
+	gzip    bool
+}
+
+// NewSender creates a sender for the server at host, given either as
+// "localhost:8080" or as a full http(s) URL.
+func NewSender(host string, opts ...Option) (*Sender, error) {
+	o := newOptions(opts)
+
+	baseURL := host
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid server address %q: %w", host, err)
+	}
+	if o.retryWaitMin > o.retryWaitMax {
+		return nil, fmt.Errorf("retry wait min %s is greater than retry wait max %s", o.retryWaitMin, o.retryWaitMax)
+	}
+
+	retClient := retryablehttp.NewClient()
+	retClient.Logger = nil
+	retClient.Backoff = Backoff
+	retClient.CheckRetry = CheckRetry
+	retClient.RetryMax = o.retryMax
+	retClient.RetryWaitMin = o.retryWaitMin
+	retClient.RetryWaitMax = o.retryWaitMax
+
+	return &Sender{
+		client:  retClient.StandardClient(),
+		headers: o.headers,
+		baseURL: baseURL,
+		key:     o.key,
+		gzip:    o.gzip,
+	}, nil
+}
+
+// Send posts a single metric to /update/.
+func (s *Sender) Send(ctx context.Context, metric Metrics) error {
+	return s.post(ctx, updatePath, metric)
+}
+
+// SendBatch posts metrics to /updates/ in one request.
+func (s *Sender) SendBatch(ctx context.Context, batch []Metrics) error {
+	return s.post(ctx, updatesPath, batch)
+}
+
+func (s *Sender) post(ctx context.Context, path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to JSON encode metrics: %w", err)
+	}
+
+	sendURL, err := url.JoinPath(s.baseURL, path, "/")
+	if err != nil {
+		return fmt.Errorf("failed to join path parts for JSON POST URL: %w", err)
+	}
+
+	hash := ""
+	if len(s.key) > 0 {
+		h := hmac.New(sha256.New, s.key)
+		h.Write(body)
+		hash = hex.EncodeToString(h.Sum(nil))
+	}
+
+	if s.gzip {
+		body, err = compress(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create a request: %w", err)
+	}
+	req.Close = true
+
+	for k, values := range s.headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if hash != "" {
+		req.Header.Set(HashHeader, hash)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to do a request, server is probably down: %w", err)
+	}
+
+	_, err = io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return errors.Join(fmt.Errorf("error copying response body: %w", err), resp.Body.Close())
+	}
+
+	err = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("error closing response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress request body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress request body: %w", err)
+	}
+	return buf.Bytes(), nil
+}